@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/devonphone/weather-aggregator/internal/cache"
+	"github.com/devonphone/weather-aggregator/internal/location"
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"github.com/devonphone/weather-aggregator/internal/providers"
+)
+
+const (
+	defaultKeyLimit = 100
+	maxKeyLimit     = 1000
+)
+
+type AdminHandler struct {
+	breakers []*providers.CircuitBreaker
+	cache    cache.Cache
+}
+
+func NewAdminHandler(breakers []*providers.CircuitBreaker, cache cache.Cache) *AdminHandler {
+	return &AdminHandler{
+		breakers: breakers,
+		cache:    cache,
+	}
+}
+
+func (h *AdminHandler) GetBreakers(w http.ResponseWriter, r *http.Request) {
+	statuses := make([]providers.BreakerStatus, 0, len(h.breakers))
+	for _, breaker := range h.breakers {
+		statuses = append(statuses, breaker.Status())
+	}
+	RespondJSON(w, statuses)
+}
+
+// ListCacheKeys lists the cached keys matching the glob in the pattern
+// parameter, by default every key of the aggregator, up to limit of them.
+func (h *AdminHandler) ListCacheKeys(w http.ResponseWriter, r *http.Request) {
+	pattern := r.URL.Query().Get("pattern")
+	if pattern == "" {
+		pattern = location.Namespace + "*"
+	}
+	if !inNamespace(w, pattern) {
+		return
+	}
+
+	limit := defaultKeyLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxKeyLimit {
+			RespondError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxKeyLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	// Ask for one more key than needed to tell whether the list is complete
+	keys, err := h.cache.Keys(r.Context(), pattern, limit+1)
+	if err != nil {
+		respondCacheError(w, err)
+		return
+	}
+	response := models.CacheKeysResponse{Keys: keys, Truncated: len(keys) > limit}
+	if response.Truncated {
+		response.Keys = keys[:limit]
+	}
+	if response.Keys == nil {
+		response.Keys = []string{}
+	}
+	RespondJSON(w, response)
+}
+
+// GetCacheEntry shows the entry under the key parameter with its remaining
+// TTL. Fetch locks are shown with their TTL only.
+func (h *AdminHandler) GetCacheEntry(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if !inNamespace(w, key) {
+		return
+	}
+	if cache.IsLockKey(key) {
+		h.getLock(w, r, key)
+		return
+	}
+
+	data, err := h.cache.Get(r.Context(), key)
+	notFound := errors.Is(err, cache.ErrNotFound)
+	if err != nil && !notFound {
+		respondCacheError(w, err)
+		return
+	}
+	if data == nil && !notFound {
+		RespondError(w, http.StatusNotFound, "No cache entry for key "+key)
+		return
+	}
+
+	ttl, err := h.cache.TTL(r.Context(), key)
+	if err != nil {
+		respondCacheError(w, err)
+		return
+	}
+	RespondJSON(w, models.CacheEntryResponse{
+		Key:        key,
+		TTLSeconds: int64(ttl.Seconds()),
+		NotFound:   notFound,
+		Data:       data,
+	})
+}
+
+func (h *AdminHandler) getLock(w http.ResponseWriter, r *http.Request, key string) {
+	ttl, err := h.cache.TTL(r.Context(), key)
+	if err != nil {
+		respondCacheError(w, err)
+		return
+	}
+	if ttl <= 0 {
+		RespondError(w, http.StatusNotFound, "No cache entry for key "+key)
+		return
+	}
+	RespondJSON(w, models.CacheEntryResponse{
+		Key:        key,
+		TTLSeconds: int64(ttl.Seconds()),
+		Lock:       true,
+	})
+}
+
+// PurgeCache deletes the entry under the key parameter, or every entry
+// starting with the prefix parameter.
+func (h *AdminHandler) PurgeCache(w http.ResponseWriter, r *http.Request) {
+	key, prefix := r.URL.Query().Get("key"), r.URL.Query().Get("prefix")
+	switch {
+	case key != "" && prefix != "":
+		RespondError(w, http.StatusBadRequest, "Pass either key or prefix, not both")
+	case key != "":
+		if !inNamespace(w, key) {
+			return
+		}
+		deleted, err := h.cache.Delete(r.Context(), key)
+		if err != nil {
+			respondCacheError(w, err)
+			return
+		}
+		RespondJSON(w, models.CachePurgeResponse{Deleted: deleted})
+	default:
+		if !inNamespace(w, prefix) {
+			return
+		}
+		h.purgePrefix(w, r, prefix)
+	}
+}
+
+// FlushCache deletes every entry in the aggregator's namespace, leaving any
+// other data in a shared Redis alone.
+func (h *AdminHandler) FlushCache(w http.ResponseWriter, r *http.Request) {
+	h.purgePrefix(w, r, location.Namespace)
+}
+
+func (h *AdminHandler) purgePrefix(w http.ResponseWriter, r *http.Request, prefix string) {
+	deleted, err := h.cache.DeletePrefix(r.Context(), prefix)
+	if err != nil {
+		respondCacheError(w, err)
+		return
+	}
+	RespondJSON(w, models.CachePurgeResponse{Deleted: deleted})
+}
+
+// inNamespace rejects keys, patterns and prefixes outside the aggregator's
+// namespace, so the admin API cannot touch other data in a shared Redis.
+func inNamespace(w http.ResponseWriter, key string) bool {
+	if !strings.HasPrefix(key, location.Namespace) {
+		RespondError(w, http.StatusBadRequest, "Key must start with "+location.Namespace)
+		return false
+	}
+	return true
+}
+
+func respondCacheError(w http.ResponseWriter, err error) {
+	if errors.Is(err, cache.ErrUnavailable) {
+		RespondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	RespondError(w, http.StatusInternalServerError, "Cache error: "+err.Error())
+}
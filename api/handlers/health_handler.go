@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/devonphone/weather-aggregator/internal/cache"
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+type HealthHandler struct {
+	cache cache.Cache
+}
+
+func NewHealthHandler(cache cache.Cache) *HealthHandler {
+	return &HealthHandler{
+		cache: cache,
+	}
+}
+
+// GetHealth reports "ok", or "degraded" while the cache backend is
+// unreachable. The service keeps answering from the providers in that
+// state, so it still responds with 200.
+func (h *HealthHandler) GetHealth(w http.ResponseWriter, r *http.Request) {
+	response := models.HealthResponse{
+		Status: "ok",
+		Cache:  models.CacheHealth{Backend: "memory", Healthy: true},
+	}
+	if checker, ok := h.cache.(cache.HealthChecker); ok {
+		response.Cache = checker.Health()
+	}
+	if !response.Cache.Healthy {
+		response.Status = "degraded"
+	}
+	RespondJSON(w, response)
+}
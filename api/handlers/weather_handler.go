@@ -2,61 +2,257 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/devonphone/weather-aggregator/internal/aggregate"
 	"github.com/devonphone/weather-aggregator/internal/cache"
+	"github.com/devonphone/weather-aggregator/internal/location"
 	"github.com/devonphone/weather-aggregator/internal/models"
 	"github.com/devonphone/weather-aggregator/internal/providers"
 	ratelimit "github.com/devonphone/weather-aggregator/internal/rate_limit"
 	"github.com/devonphone/weather-aggregator/internal/stats"
+	"github.com/devonphone/weather-aggregator/internal/warmer"
+	"golang.org/x/sync/singleflight"
 )
 
+const (
+	defaultProviderTimeout = 5 * time.Second
+	defaultHedgeDelay      = 300 * time.Millisecond
+	lockPollInterval       = 50 * time.Millisecond
+	locationAliasCapacity  = 10000
+	defaultQueueLength     = 100
+)
+
+// FetchMode controls when providers are called on a cache miss.
+type FetchMode string
+
+const (
+	// FetchFanOut calls every provider at once.
+	FetchFanOut FetchMode = "fanout"
+	// FetchHedged calls providers one at a time in priority order, moving
+	// on to the next one when the current one fails or is slower than the
+	// hedge delay.
+	FetchHedged FetchMode = "hedged"
+)
+
+// WeatherHandlerOptions tunes how the handler queries providers. The zero
+// value keeps the first-wins behaviour with a 5 second deadline.
+type WeatherHandlerOptions struct {
+	Aggregator      *aggregate.Aggregator
+	ProviderTimeout time.Duration
+
+	FetchMode FetchMode
+	// HedgeDelay is how long a hedged fetch waits for a provider before
+	// also calling the next one.
+	HedgeDelay time.Duration
+	// HedgePercentile, when set, replaces HedgeDelay with that percentile
+	// of the provider's observed latency once enough calls were made.
+	HedgePercentile float64
+
+	// FetchLockTTL enables cross-replica fetch locking when the cache
+	// implements cache.Locker: only the replica holding the lock for a city
+	// calls the providers. Zero disables it.
+	FetchLockTTL time.Duration
+	// FetchLockWait is how long other replicas wait for the lock holder's
+	// result to show up in the cache before fetching themselves.
+	FetchLockWait time.Duration
+
+	// FreshFor is how long a cached entry is served as-is. Zero treats
+	// every cached entry as fresh.
+	FreshFor time.Duration
+	// StaleWhileRevalidate is how long past FreshFor an entry is still
+	// served immediately, marked stale, while it is refreshed in the
+	// background.
+	StaleWhileRevalidate time.Duration
+	// StaleIfError is how long past FreshFor an entry is served, marked
+	// stale, when every provider fails to refresh it.
+	StaleIfError time.Duration
+
+	// NotFoundTTL is how long a location every provider reported as unknown
+	// is answered with 404 from the cache. Zero disables negative caching.
+	NotFoundTTL time.Duration
+
+	// Warmer, when set, is told about every request so it can keep popular
+	// locations warm.
+	Warmer *warmer.Warmer
+
+	// ClientLimiter, when set, rate limits each client identified by
+	// ClientIdentifier separately, with the global limiter still capping
+	// all of them together. Background refreshes only use the global
+	// limiter.
+	ClientLimiter    ratelimit.Keyed
+	ClientIdentifier *ratelimit.ClientIdentifier
+
+	// RateLimitQueueWait, when set, makes cache misses over the rate limit
+	// wait up to this long for the limit to allow them instead of being
+	// rejected right away. Requests that would have to wait longer are
+	// still rejected. Zero disables queueing.
+	RateLimitQueueWait time.Duration
+	// RateLimitQueueLength caps how many requests wait at once; requests
+	// beyond it are rejected. Defaults to 100.
+	RateLimitQueueLength int
+}
+
 type WeatherHandler struct {
-	providers   []providers.WeatherProvider
-	cache       cache.Cache
-	rateLimiter ratelimit.RateLimiter
-	stats       *stats.StatsTracker
+	providers            []providers.WeatherProvider
+	cache                cache.Cache
+	rateLimiter          ratelimit.RateLimiter
+	stats                *stats.StatsTracker
+	aggregator           *aggregate.Aggregator
+	providerTimeout      time.Duration
+	fetchMode            FetchMode
+	hedgeDelay           time.Duration
+	hedgePercentile      float64
+	latencies            *stats.LatencyTracker
+	fetches              singleflight.Group
+	fetchLockTTL         time.Duration
+	fetchLockWait        time.Duration
+	freshFor             time.Duration
+	staleWhileRevalidate time.Duration
+	staleIfError         time.Duration
+	revalidating         sync.Map
+	notFoundTTL          time.Duration
+	warmer               *warmer.Warmer
+	clientLimiter        ratelimit.Keyed
+	clientIdentifier     *ratelimit.ClientIdentifier
+	queueWait            time.Duration
+	queueLength          int64
+	queued               atomic.Int64
+	locations            *location.Resolver
 }
 
 func NewWeatherHandler(
 	providers []providers.WeatherProvider,
 	cache cache.Cache,
 	rateLimiter ratelimit.RateLimiter,
-	stats *stats.StatsTracker,
+	statsTracker *stats.StatsTracker,
+	opts WeatherHandlerOptions,
 ) *WeatherHandler {
+	if opts.Aggregator == nil {
+		opts.Aggregator = aggregate.NewAggregator(aggregate.FirstWins, nil, 1, aggregate.Thresholds{})
+	}
+	if opts.ProviderTimeout <= 0 {
+		opts.ProviderTimeout = defaultProviderTimeout
+	}
+	if opts.FetchMode == "" {
+		opts.FetchMode = FetchFanOut
+	}
+	if opts.HedgeDelay <= 0 {
+		opts.HedgeDelay = defaultHedgeDelay
+	}
+	if opts.RateLimitQueueLength <= 0 {
+		opts.RateLimitQueueLength = defaultQueueLength
+	}
+
 	return &WeatherHandler{
-		providers:   providers,
-		cache:       cache,
-		rateLimiter: rateLimiter,
-		stats:       stats,
+		providers:            providers,
+		cache:                cache,
+		rateLimiter:          rateLimiter,
+		stats:                statsTracker,
+		aggregator:           opts.Aggregator,
+		providerTimeout:      opts.ProviderTimeout,
+		fetchMode:            opts.FetchMode,
+		hedgeDelay:           opts.HedgeDelay,
+		hedgePercentile:      opts.HedgePercentile,
+		latencies:            stats.NewLatencyTracker(),
+		fetchLockTTL:         opts.FetchLockTTL,
+		fetchLockWait:        opts.FetchLockWait,
+		freshFor:             opts.FreshFor,
+		staleWhileRevalidate: opts.StaleWhileRevalidate,
+		staleIfError:         opts.StaleIfError,
+		notFoundTTL:          opts.NotFoundTTL,
+		warmer:               opts.Warmer,
+		clientLimiter:        opts.ClientLimiter,
+		clientIdentifier:     opts.ClientIdentifier,
+		queueWait:            opts.RateLimitQueueWait,
+		queueLength:          int64(opts.RateLimitQueueLength),
+		locations:            location.NewResolver(locationAliasCapacity),
 	}
 }
 
 func (h *WeatherHandler) GetWeather(w http.ResponseWriter, r *http.Request) {
 	h.stats.IncrementRequests()
 
-	city := r.URL.Query().Get("city")
+	// Every response tells the client where it stands, even though only
+	// cache misses count against the limit. Misses report the state left
+	// after taking a request, everything else looks the limit up once.
+	limiter := h.limiterFor(r)
+	reportLimit := func() {
+		setRateLimitHeaders(w, limiter.Status())
+	}
+
+	city := strings.TrimSpace(r.URL.Query().Get("city"))
 	if city == "" {
+		reportLimit()
 		RespondError(w, http.StatusBadRequest, "City parameter is required")
 		return
 	}
 
 	// Check cache first
-	if weatherData, err := h.cache.Get(r.Context(), city); err == nil && weatherData != nil {
-		h.stats.IncrementCacheHits()
-		log.Printf("[DEBUG] Cache hit for city: %s", city)
-		RespondJSON(w, weatherData)
+	key := h.locations.Key(city)
+	if h.warmer != nil {
+		h.warmer.Record(key, city)
+	}
+	var fallback *models.WeatherData
+	weatherData, err := h.cache.Get(r.Context(), key)
+	if errors.Is(err, cache.ErrNotFound) {
+		h.stats.IncrementNegativeCacheHits()
+		log.Printf("[DEBUG] Negative cache hit for city: %s", city)
+		code, message := fetchErrorResponse(city, err)
+		reportLimit()
+		RespondError(w, code, message)
 		return
 	}
-	h.stats.IncrementCacheMisses()
-	log.Printf("[DEBUG] Cache miss for city: %s", city)
+	if err == nil && weatherData != nil {
+		age := cachedAge(weatherData)
+		switch {
+		case h.isFresh(weatherData):
+			h.stats.IncrementCacheHits()
+			log.Printf("[DEBUG] Cache hit for city: %s", city)
+			reportLimit()
+			RespondJSON(w, weatherData)
+			return
+		case age <= h.freshFor+h.staleWhileRevalidate:
+			h.stats.IncrementStaleHits()
+			log.Printf("[DEBUG] Stale cache hit for city: %s, revalidating in background", city)
+			h.revalidate(city, key)
+			reportLimit()
+			RespondJSON(w, markStale(weatherData, age))
+			return
+		case age <= h.freshFor+h.staleIfError:
+			// Too old to serve outright, but better than an error
+			fallback = markStale(weatherData, age)
+		}
+	}
+	if err != nil {
+		// Count failed lookups apart from misses so an outage is visible
+		h.stats.IncrementCacheErrors()
+		log.Printf("[WARN] Cache error for city %s, bypassing the cache: %v", city, err)
+	} else {
+		h.stats.IncrementCacheMisses()
+		log.Printf("[DEBUG] Cache miss for city: %s", city)
+	}
 
-	// Check rate limit
-	if !h.rateLimiter.Allow() {
+	// Check rate limit, queueing for it if that is enabled and the wait is
+	// short enough
+	status := limiter.Take()
+	if !status.Allowed && h.queueWait > 0 && status.RetryAfter <= h.queueWait {
+		status = h.waitForLimit(r.Context(), limiter, status)
+	}
+	setRateLimitHeaders(w, status)
+	if !status.Allowed {
 		h.stats.IncrementRateLimitHits()
+		w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(status.RetryAfter)))
 		RespondError(w, http.StatusTooManyRequests, "Rate limit exceeded")
 		return
 	}
@@ -64,70 +260,423 @@ func (h *WeatherHandler) GetWeather(w http.ResponseWriter, r *http.Request) {
 	// Log when fetching data from providers
 	log.Printf("[DEBUG] Fetching weather data from providers for city: %s", city)
 
-	// Fetch from all providers concurrently
-	weatherData := h.fetchFromProviders(r.Context(), city)
-	if weatherData == nil {
-		RespondError(w, http.StatusServiceUnavailable, "Failed to fetch weather data")
+	data, err := h.fetchCoalesced(r.Context(), city, key)
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch weather data for city %s: %v", city, err)
+		if fallback != nil {
+			h.stats.IncrementStaleIfErrorHits()
+			log.Printf("[WARN] Serving stale data for city: %s aged %ds", city, fallback.AgeSeconds)
+			RespondJSON(w, fallback)
+			return
+		}
+		if h.notFoundTTL > 0 && locationNotFound(err) {
+			if err := h.cache.SetNotFound(r.Context(), key, h.notFoundTTL); err != nil {
+				h.stats.IncrementCacheErrors()
+				log.Printf("[ERROR] Cache set error: %v", err)
+			}
+		}
+		code, message := fetchErrorResponse(city, err)
+		RespondError(w, code, message)
 		return
 	}
 
-	data, ok := weatherData.(*models.WeatherData)
-	if !ok {
-		RespondError(w, http.StatusInternalServerError, "Unexpected data type returned from fetchFromProviders")
+	log.Printf("[INFO] Weather data fetched successfully for city: %s", city)
+	RespondJSON(w, data)
+}
+
+// limiterFor returns the rate limiter for the client making r: its own
+// limit, with the global limit on top as an overall cap.
+func (h *WeatherHandler) limiterFor(r *http.Request) ratelimit.RateLimiter {
+	if h.clientLimiter == nil || h.clientIdentifier == nil {
+		return h.rateLimiter
+	}
+	return ratelimit.Combined{h.clientLimiter.Limiter(h.clientIdentifier.Identify(r)), h.rateLimiter}
+}
+
+// waitForLimit queues a request that limiter rejected with status until the
+// limit allows it, the queue wait passes or ctx is done. It returns the
+// limit's state once the request is allowed, or status when the queue is
+// full or the wait fails.
+func (h *WeatherHandler) waitForLimit(ctx context.Context, limiter ratelimit.RateLimiter, status ratelimit.Status) ratelimit.Status {
+	if h.queued.Add(1) > h.queueLength {
+		h.queued.Add(-1)
+		log.Printf("[DEBUG] Rate limit queue is full, rejecting request")
+		return status
+	}
+	defer h.queued.Add(-1)
+
+	ctx, cancel := context.WithTimeout(ctx, h.queueWait)
+	defer cancel()
+	if err := limiter.Wait(ctx); err != nil {
+		log.Printf("[DEBUG] Gave up waiting for the rate limit: %v", err)
+		return status
+	}
+
+	h.stats.IncrementRateLimitQueued()
+	status = limiter.Status()
+	status.Allowed = true
+	return status
+}
+
+// setRateLimitHeaders reports status in the RateLimit-* headers of the
+// IETF rate limit headers draft.
+func setRateLimitHeaders(w http.ResponseWriter, status ratelimit.Status) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(status.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(status.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(status.Reset)))
+}
+
+// ceilSeconds rounds d up to whole seconds, so clients that wait that long
+// are not turned away again.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// revalidate refreshes city's cache entry under key in the background,
+// unless a refresh for it is already running.
+func (h *WeatherHandler) revalidate(city, key string) {
+	if _, running := h.revalidating.LoadOrStore(key, struct{}{}); running {
 		return
 	}
 
-	// Cache the result
-	if err := h.cache.Set(r.Context(), city, data); err != nil {
-		log.Printf("[ERROR] Cache set error: %v", err)
+	go func() {
+		defer h.revalidating.Delete(key)
+
+		if !h.rateLimiter.Allow() {
+			log.Printf("[DEBUG] Skipping revalidation of city: %s, rate limit exceeded", city)
+			return
+		}
+		if _, err := h.fetchCoalesced(context.Background(), city, key); err != nil {
+			log.Printf("[ERROR] Background revalidation failed for city %s: %v", city, err)
+		}
+	}()
+}
+
+// Warm refreshes city's cache entry when it is missing or goes stale within
+// refreshAhead, reporting whether the providers were called. Locations known
+// to be unknown are left alone, and so is everything while the global rate
+// limit is used up.
+func (h *WeatherHandler) Warm(ctx context.Context, city string, refreshAhead time.Duration) (bool, error) {
+	key := h.locations.Key(city)
+	data, err := h.cache.Get(ctx, key)
+	switch {
+	case errors.Is(err, cache.ErrNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	case data != nil && (h.freshFor <= 0 || cachedAge(data) < h.freshFor-refreshAhead):
+		return false, nil
 	}
 
-	log.Printf("[INFO] Weather data fetched successfully for city: %s", city)
-	RespondJSON(w, weatherData)
+	// Warming is best effort: when the limit is used up, leave it to user
+	// requests and try again on the next run
+	if !h.rateLimiter.Allow() {
+		log.Printf("[DEBUG] Skipping warming of city: %s, rate limit exceeded", city)
+		return false, nil
+	}
+	if _, err := h.fetchCoalesced(ctx, city, key); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isFresh reports whether a cached entry may be served without refreshing.
+func (h *WeatherHandler) isFresh(data *models.WeatherData) bool {
+	return h.freshFor <= 0 || cachedAge(data) <= h.freshFor
+}
+
+// cachedAge returns how long ago data was written to the cache.
+func cachedAge(data *models.WeatherData) time.Duration {
+	if data.CachedAt == nil {
+		return 0
+	}
+	return time.Since(*data.CachedAt)
+}
+
+func markStale(data *models.WeatherData, age time.Duration) *models.WeatherData {
+	data.Stale = true
+	data.AgeSeconds = int64(age / time.Second)
+	return data
 }
 
-func (h *WeatherHandler) fetchFromProviders(ctx context.Context, city string) interface{} {
-	var wg sync.WaitGroup
-	results := make(chan interface{}, len(h.providers))
-	errors := make(chan error, len(h.providers))
+// fetchCoalesced fetches and caches weather for city, sharing one upstream
+// fetch between concurrent cache misses for the same location key.
+func (h *WeatherHandler) fetchCoalesced(ctx context.Context, city, key string) (*models.WeatherData, error) {
+	leader := false
+	result, err, _ := h.fetches.Do(key, func() (interface{}, error) {
+		leader = true
+
+		// The fetch is shared, so one client going away must not cancel it
+		// for everyone else
+		return h.fetchLocked(context.WithoutCancel(ctx), city, key)
+	})
+
+	if !leader {
+		h.stats.IncrementCoalescedRequests()
+		log.Printf("[DEBUG] Coalesced request for city: %s into an in-flight fetch", city)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.WeatherData), nil
+}
 
-	// Fetch data from each provider concurrently
-	for _, provider := range h.providers {
-		wg.Add(1)
-		go func(provider providers.WeatherProvider) {
-			defer wg.Done()
+// fetchLocked fetches and caches weather for city. When the cache is shared
+// between replicas it first takes the fetch lock on key; if another replica
+// holds it, it waits for that replica's result to land in the cache instead.
+func (h *WeatherHandler) fetchLocked(ctx context.Context, city, key string) (*models.WeatherData, error) {
+	if locker, ok := h.cache.(cache.Locker); ok && h.fetchLockTTL > 0 {
+		unlock, acquired, err := locker.TryLock(ctx, key, h.fetchLockTTL)
+		switch {
+		case err != nil:
+			h.stats.IncrementCacheErrors()
+			log.Printf("[ERROR] Cache lock error for city %s, fetching without it: %v", city, err)
+		case acquired:
+			defer unlock()
+		default:
+			if data, err := h.waitForCache(ctx, key); data != nil || err != nil {
+				h.stats.IncrementRemoteCoalescedRequests()
+				log.Printf("[DEBUG] Served city: %s from another replica's fetch", city)
+				return data, err
+			}
+			log.Printf("[WARN] Timed out waiting for another replica's fetch of city: %s", city)
+		}
+	}
+
+	data, err := h.fetchFromProviders(ctx, city)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the result under the query's key and, once known, under the
+	// key of the location the providers resolved it to
+	for _, cacheKey := range h.locations.Learn(city, data) {
+		if err := h.cache.Set(ctx, cacheKey, data); err != nil {
+			h.stats.IncrementCacheErrors()
+			log.Printf("[ERROR] Cache set error: %v", err)
+		}
+	}
+	return data, nil
+}
+
+// waitForCache polls the cache for a fresh entry for key until one shows up
+// or the fetch lock wait runs out, returning nil in the latter case. If the
+// other replica found the location to be unknown it returns
+// cache.ErrNotFound.
+func (h *WeatherHandler) waitForCache(ctx context.Context, key string) (*models.WeatherData, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.fetchLockWait)
+	defer cancel()
+
+	ticker := time.NewTicker(lockPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case <-ticker.C:
+			data, err := h.cache.Get(ctx, key)
+			if errors.Is(err, cache.ErrNotFound) {
+				return nil, err
+			}
+			if err == nil && data != nil && h.isFresh(data) {
+				return data, nil
+			}
+		}
+	}
+}
+
+// fetchErrorResponse maps a failed fetch to a status code and message:
+// 404 when every provider reported the location as unknown, 503 when any
+// provider was unavailable, out of quota or too slow, and 502 when the
+// providers answered but rejected our credentials or sent garbage.
+func fetchErrorResponse(city string, err error) (int, string) {
+	switch {
+	case errors.Is(err, cache.ErrNotFound), locationNotFound(err):
+		return http.StatusNotFound, fmt.Sprintf("Location %q was not found by any weather provider", city)
+	case errors.Is(err, providers.ErrUpstreamUnavailable),
+		errors.Is(err, providers.ErrQuotaExceeded),
+		errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, aggregate.ErrQuorumNotMet):
+		return http.StatusServiceUnavailable, "Weather providers are temporarily unavailable: " + err.Error()
+	default:
+		return http.StatusBadGateway, "Weather providers returned an unusable response: " + err.Error()
+	}
+}
+
+// locationNotFound reports whether err is a fetch error in which every
+// provider reported the location as unknown.
+func locationNotFound(err error) bool {
+	var fetchErr *providers.FetchError
+	if !errors.As(err, &fetchErr) || len(fetchErr.Errors) == 0 {
+		return false
+	}
+	for _, providerErr := range fetchErr.Errors {
+		if !errors.Is(providerErr, providers.ErrLocationNotFound) {
+			return false
+		}
+	}
+	return true
+}
+
+// providerResult is one provider's answer; index is the provider's position
+// in the priority-ordered providers slice.
+type providerResult struct {
+	index int
+	data  *models.WeatherData
+	err   error
+}
+
+// fetchFromProviders queries the providers and fuses the results. In
+// fan-out mode every provider is called at once; in hedged mode providers
+// are called in priority order, each one only after the previous one failed
+// or exceeded the hedge delay. A failing provider does not end the fetch: it
+// only fails once every provider has failed or the deadline passed,
+// returning a *providers.FetchError that lists each provider's reason.
+func (h *WeatherHandler) fetchFromProviders(ctx context.Context, city string) (*models.WeatherData, error) {
+	ctx, cancel := context.WithTimeout(ctx, h.providerTimeout)
+	defer cancel()
+
+	results := make(chan providerResult, len(h.providers))
+
+	launched := 0
+	var hedge <-chan time.Time
+	launch := func() {
+		index, provider := launched, h.providers[launched]
+		launched++
+		if h.fetchMode == FetchHedged && launched < len(h.providers) {
+			hedge = time.After(h.hedgeDelayFor(provider))
+		}
+
+		go func() {
 			log.Printf("Fetching data from provider: %s for city: %s\n", provider.GetProviderName(), city)
 
-			// Increment API call count
-			h.stats.IncrementApiCalls()
+			start := time.Now()
 			weatherData, err := provider.GetWeather(ctx, city)
+			// Increment API call count, leaving out calls the quota budget or
+			// an open circuit breaker refused before they reached the provider
+			switch {
+			case errors.Is(err, providers.ErrBudgetExhausted):
+				h.stats.RecordBudgetExhausted(provider.GetProviderName())
+			case errors.Is(err, providers.ErrCircuitOpen):
+			default:
+				h.stats.IncrementApiCalls()
+			}
 			if err != nil {
 				log.Printf("Error from provider %s: %v\n", provider.GetProviderName(), err)
-				errors <- err
-				return
+			} else {
+				h.latencies.Observe(provider.GetProviderName(), time.Since(start))
+				log.Printf("Received data from provider %s: %+v\n", provider.GetProviderName(), weatherData)
 			}
-			log.Printf("Received data from provider %s: %+v\n", provider.GetProviderName(), weatherData)
-			results <- weatherData
-		}(provider)
+			results <- providerResult{index: index, data: weatherData, err: err}
+		}()
 	}
 
-	// Close the channels when all goroutines finish
-	go func() {
-		wg.Wait()
-		close(results)
-		close(errors)
-	}()
+	if h.fetchMode == FetchHedged {
+		launch()
+	} else {
+		for launched < len(h.providers) {
+			launch()
+		}
+	}
+
+	// Collect results until the aggregation strategy has enough of them,
+	// every provider has answered or the deadline passes
+	var collected []providerResult
+	failures := make([]error, len(h.providers))
+	answered := 0
+collect:
+	for answered < len(h.providers) && !h.aggregator.Satisfied(len(collected), len(h.providers)) {
+		// Nothing in flight: move on to the next provider right away
+		if answered == launched {
+			launch()
+			continue
+		}
+
+		select {
+		case result := <-results:
+			answered++
+			if result.err != nil {
+				failures[result.index] = result.err
+				// An error is an answer too: fall back without waiting out the hedge delay
+				if h.fetchMode == FetchHedged && launched < len(h.providers) {
+					launch()
+				}
+				continue
+			}
+			collected = append(collected, result)
+		case <-hedge:
+			hedge = nil
+			if launched < len(h.providers) {
+				log.Printf("Hedging request for city: %s to provider %s", city, h.providers[launched].GetProviderName())
+				launch()
+			}
+		case <-ctx.Done():
+			log.Printf("Provider deadline reached with %d of %d results", len(collected), len(h.providers))
+			break collect
+		}
+	}
+
+	// Fuse in priority order so the preferred provider's fields win
+	sort.Slice(collected, func(i, j int) bool {
+		return collected[i].index < collected[j].index
+	})
+	data := make([]*models.WeatherData, 0, len(collected))
+	for _, result := range collected {
+		data = append(data, result.data)
+	}
+
+	weatherData, err := h.aggregator.Fuse(data)
+	if err != nil {
+		return nil, h.fetchError(collected, failures, launched, err)
+	}
+
+	for _, provider := range weatherData.Outliers {
+		log.Printf("[WARN] Provider %s disagreed with the consensus for city: %s", provider, city)
+		h.stats.RecordOutlier(provider)
+	}
+
+	log.Printf("Aggregated %d provider result(s) using %s strategy.", len(collected), h.aggregator.Strategy())
+	return weatherData, nil
+}
+
+// fetchError builds the combined error for a failed fetch. Providers that
+// neither failed nor answered in time, or were never called before the
+// deadline, are reported as timed out; providers that answered are reported
+// with the aggregation error.
+func (h *WeatherHandler) fetchError(collected []providerResult, failures []error, launched int, aggregateErr error) error {
+	succeeded := make(map[int]bool, len(collected))
+	for _, result := range collected {
+		succeeded[result.index] = true
+	}
+
+	fetchErr := &providers.FetchError{}
+	for i, provider := range h.providers {
+		err := failures[i]
+		switch {
+		case err != nil:
+		case succeeded[i]:
+			err = aggregateErr
+		case i >= launched:
+			err = fmt.Errorf("not called before deadline: %w", context.DeadlineExceeded)
+		default:
+			err = context.DeadlineExceeded
+		}
+		fetchErr.Errors = append(fetchErr.Errors, &providers.ProviderError{
+			Provider: provider.GetProviderName(),
+			Err:      err,
+		})
+	}
+	return fetchErr
+}
 
-	// Wait for the first successful result or return nil if all fail
-	select {
-	case weatherData := <-results:
-		log.Println("Successfully fetched data from a provider.")
-		return weatherData
-	case <-time.After(5 * time.Second):
-		log.Println("All providers timed out")
-		return nil
-	case err := <-errors:
-		log.Printf("Provider error occurred: %v\n", err)
-		return nil
+// hedgeDelayFor returns how long to wait on provider before hedging to the
+// next one.
+func (h *WeatherHandler) hedgeDelayFor(provider providers.WeatherProvider) time.Duration {
+	if h.hedgePercentile > 0 {
+		if latency, ok := h.latencies.Percentile(provider.GetProviderName(), h.hedgePercentile); ok {
+			return latency
+		}
 	}
+	return h.hedgeDelay
 }
@@ -1,8 +1,10 @@
 package routes
 
 import (
+	"crypto/subtle"
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/devonphone/weather-aggregator/api/handlers"
 	"github.com/gorilla/mux"
@@ -11,15 +13,24 @@ import (
 type API struct {
     weatherHandler *handlers.WeatherHandler
     statsHandler  *handlers.StatsHandler
+    healthHandler *handlers.HealthHandler
+    adminHandler  *handlers.AdminHandler
+    adminToken    string
 }
 
 func NewAPI(
     weatherHandler *handlers.WeatherHandler,
     statsHandler *handlers.StatsHandler,
+    healthHandler *handlers.HealthHandler,
+    adminHandler *handlers.AdminHandler,
+    adminToken string,
 ) *API {
     return &API{
         weatherHandler: weatherHandler,
         statsHandler:  statsHandler,
+        healthHandler: healthHandler,
+        adminHandler:  adminHandler,
+        adminToken:    adminToken,
     }
 }
 
@@ -29,6 +40,22 @@ func (api *API) SetupRoutes(router *mux.Router) {
     
     // Stats endpoints
     router.HandleFunc("/stats", api.statsHandler.GetStats).Methods("GET")
+
+    // Health endpoint
+    router.HandleFunc("/health", api.healthHandler.GetHealth).Methods("GET")
+
+    // Admin endpoints are only exposed when a token is configured
+    if api.adminToken != "" {
+        admin := router.PathPrefix("/admin").Subrouter()
+        admin.Use(api.adminAuthMiddleware)
+        admin.HandleFunc("/breakers", api.adminHandler.GetBreakers).Methods("GET")
+        admin.HandleFunc("/cache/keys", api.adminHandler.ListCacheKeys).Methods("GET")
+        admin.HandleFunc("/cache/entries", api.adminHandler.GetCacheEntry).Methods("GET")
+        admin.HandleFunc("/cache/entries", api.adminHandler.PurgeCache).Methods("DELETE")
+        admin.HandleFunc("/cache", api.adminHandler.FlushCache).Methods("DELETE")
+    } else {
+        log.Println("ADMIN_TOKEN is not set, admin endpoints are disabled")
+    }
     
     // Add middleware for all routes
     router.Use(api.loggingMiddleware)
@@ -45,4 +72,16 @@ func (api *API) loggingMiddleware(next http.Handler) http.Handler {
         )
         next.ServeHTTP(w, r)
     })
+}
+
+// adminAuthMiddleware requires an "Authorization: Bearer <ADMIN_TOKEN>" header.
+func (api *API) adminAuthMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+        if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(api.adminToken)) != 1 {
+            handlers.RespondError(w, http.StatusUnauthorized, "Valid admin token required")
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
 }
\ No newline at end of file
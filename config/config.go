@@ -1,9 +1,11 @@
 package config
 
 import (
+    "fmt"
     "github.com/joho/godotenv"
     "os"
     "strconv"
+    "strings"
     "time"
 )
 
@@ -11,10 +13,64 @@ type Config struct {
     Port                string
     OpenWeatherApiKey   string
     WeatherApiKey       string
+    NWSUserAgent        string
     RedisUrl           string
+    RedisAddr          string
+    RedisUsername      string
+    RedisPassword      string
+    RedisMode          string
+    RedisAddrs         []string
+    RedisSentinelMaster   string
+    RedisSentinelPassword string
+    CacheBackend       string
+    CacheMaxEntries    int
+    CacheL1TTL         time.Duration
     CacheDuration      time.Duration
+    CacheStaleWhileRevalidate time.Duration
+    CacheStaleIfError  time.Duration
+    CacheNotFoundTTL   time.Duration
     RateLimitRequests  int
     RateLimitDuration  time.Duration
+    RateLimitGlobalRequests int
+    RateLimitScope     string
+    RateLimitBackend   string
+    RateLimitAPIKeyHeader    string
+    RateLimitAPIKeys         []string
+    RateLimitTrustedProxies  []string
+    RateLimitIdleTTL         time.Duration
+    RateLimitMaxClients      int
+    RateLimitMode            string
+    RateLimitQueueWait       time.Duration
+    RateLimitQueueLength     int
+    ProviderTimeout    time.Duration
+    ProviderPriority   []string
+    FetchMode          string
+    HedgeDelay         time.Duration
+    HedgePercentile    float64
+    FetchLockTTL       time.Duration
+    FetchLockWait      time.Duration
+    AggregationStrategy string
+    AggregationQuorum  int
+    ProviderWeights    map[string]float64
+    OutlierTemperatureThreshold float64
+    OutlierHumidityThreshold    int
+    BreakerFailureThreshold     int
+    BreakerErrorRate            float64
+    BreakerMinRequests          int
+    BreakerWindow               time.Duration
+    BreakerCoolOff              time.Duration
+    AdminToken                  string
+    RetryMaxAttempts            int
+    RetryBaseDelay              time.Duration
+    RetryMaxDelay               time.Duration
+    ProviderQuotas              string
+    QuotaReserve                float64
+    WarmPinnedCities            []string
+    WarmTopN                    int
+    WarmMinRequests             float64
+    WarmInterval                time.Duration
+    WarmRefreshAhead            time.Duration
+    WarmHalfLife                time.Duration
 }
 
 func LoadConfig() (*Config, error) {
@@ -23,17 +79,110 @@ func LoadConfig() (*Config, error) {
     }
 
     rateLimitReq, _ := strconv.Atoi(getEnv("RATE_LIMIT_REQUESTS", "60"))
+    // The global cap defaults well above a single client's limit so one busy
+    // client can't use it up for everybody else
+    rateLimitGlobalReq, _ := strconv.Atoi(getEnv("RATE_LIMIT_GLOBAL_REQUESTS", strconv.Itoa(10*rateLimitReq)))
+    rateLimitMaxClients, _ := strconv.Atoi(getEnv("RATE_LIMIT_MAX_CLIENTS", "10000"))
+    cacheMaxEntries, _ := strconv.Atoi(getEnv("CACHE_MAX_ENTRIES", "10000"))
+    cacheL1TTL, _ := time.ParseDuration(getEnv("CACHE_L1_TTL", "30s"))
     cacheDuration, _ := time.ParseDuration(getEnv("CACHE_DURATION", "30m"))
+    staleWhileRevalidate, _ := time.ParseDuration(getEnv("CACHE_STALE_WHILE_REVALIDATE", "5m"))
+    staleIfError, _ := time.ParseDuration(getEnv("CACHE_STALE_IF_ERROR", "1h"))
+    notFoundTTL, _ := time.ParseDuration(getEnv("CACHE_NOT_FOUND_TTL", "5m"))
     rateLimitDuration, _ := time.ParseDuration(getEnv("RATE_LIMIT_DURATION", "1m"))
+    rateLimitIdleTTL, _ := time.ParseDuration(getEnv("RATE_LIMIT_IDLE_TTL", "10m"))
+    rateLimitQueueWait, _ := time.ParseDuration(getEnv("RATE_LIMIT_QUEUE_WAIT", "2s"))
+    rateLimitQueueLength, _ := strconv.Atoi(getEnv("RATE_LIMIT_QUEUE_LENGTH", "100"))
+    providerTimeout, _ := time.ParseDuration(getEnv("PROVIDER_TIMEOUT", "5s"))
+    hedgeDelay, _ := time.ParseDuration(getEnv("HEDGE_DELAY", "300ms"))
+    hedgePercentile, _ := strconv.ParseFloat(getEnv("HEDGE_PERCENTILE", "0"), 64)
+    fetchLockTTL, _ := time.ParseDuration(getEnv("FETCH_LOCK_TTL", "10s"))
+    fetchLockWait, _ := time.ParseDuration(getEnv("FETCH_LOCK_WAIT", "5s"))
+    aggregationQuorum, _ := strconv.Atoi(getEnv("AGGREGATION_QUORUM", "2"))
+    outlierTemperature, _ := strconv.ParseFloat(getEnv("OUTLIER_TEMPERATURE_THRESHOLD", "3"), 64)
+    outlierHumidity, _ := strconv.Atoi(getEnv("OUTLIER_HUMIDITY_THRESHOLD", "15"))
+    breakerFailures, _ := strconv.Atoi(getEnv("BREAKER_FAILURE_THRESHOLD", "5"))
+    breakerErrorRate, _ := strconv.ParseFloat(getEnv("BREAKER_ERROR_RATE", "0.5"), 64)
+    breakerMinRequests, _ := strconv.Atoi(getEnv("BREAKER_MIN_REQUESTS", "10"))
+    breakerWindow, _ := time.ParseDuration(getEnv("BREAKER_WINDOW", "1m"))
+    breakerCoolOff, _ := time.ParseDuration(getEnv("BREAKER_COOL_OFF", "30s"))
+    retryMaxAttempts, _ := strconv.Atoi(getEnv("RETRY_MAX_ATTEMPTS", "3"))
+    retryBaseDelay, _ := time.ParseDuration(getEnv("RETRY_BASE_DELAY", "200ms"))
+    retryMaxDelay, _ := time.ParseDuration(getEnv("RETRY_MAX_DELAY", "2s"))
+    quotaReserve, _ := strconv.ParseFloat(getEnv("QUOTA_RESERVE", "0.05"), 64)
+    warmTopN, _ := strconv.Atoi(getEnv("WARM_TOP_N", "20"))
+    warmMinRequests, _ := strconv.ParseFloat(getEnv("WARM_MIN_REQUESTS", "3"), 64)
+    warmInterval, _ := time.ParseDuration(getEnv("WARM_INTERVAL", "1m"))
+    warmRefreshAhead, _ := time.ParseDuration(getEnv("WARM_REFRESH_AHEAD", "2m"))
+    warmHalfLife, _ := time.ParseDuration(getEnv("WARM_HALF_LIFE", "24h"))
+
+    providerWeights, err := parseProviderWeights(os.Getenv("PROVIDER_WEIGHTS"))
+    if err != nil {
+        return nil, err
+    }
 
     return &Config{
         Port:               getEnv("PORT", "8080"),
         OpenWeatherApiKey:  os.Getenv("OPENWEATHER_API_KEY"),
         WeatherApiKey:      os.Getenv("WEATHERAPI_KEY"),
-        RedisUrl:          getEnv("REDIS_URL", "redis://localhost:6379"),
+        NWSUserAgent:       os.Getenv("NWS_USER_AGENT"),
+        RedisUrl:          os.Getenv("REDIS_URL"),
+        RedisAddr:         os.Getenv("REDIS_ADDR"),
+        RedisUsername:     os.Getenv("REDIS_USERNAME"),
+        RedisPassword:     os.Getenv("REDIS_PASSWORD"),
+        RedisMode:         getEnv("REDIS_MODE", "standalone"),
+        RedisAddrs:        splitList(os.Getenv("REDIS_ADDRS")),
+        RedisSentinelMaster:   os.Getenv("REDIS_SENTINEL_MASTER"),
+        RedisSentinelPassword: os.Getenv("REDIS_SENTINEL_PASSWORD"),
+        CacheBackend:      getEnv("CACHE_BACKEND", "redis"),
+        CacheMaxEntries:   cacheMaxEntries,
+        CacheL1TTL:        cacheL1TTL,
         CacheDuration:     cacheDuration,
+        CacheStaleWhileRevalidate: staleWhileRevalidate,
+        CacheStaleIfError: staleIfError,
+        CacheNotFoundTTL:  notFoundTTL,
         RateLimitRequests: rateLimitReq,
         RateLimitDuration: rateLimitDuration,
+        RateLimitGlobalRequests: rateLimitGlobalReq,
+        RateLimitScope:    getEnv("RATE_LIMIT_SCOPE", "client"),
+        RateLimitBackend:  getEnv("RATE_LIMIT_BACKEND", "memory"),
+        RateLimitAPIKeyHeader:   getEnv("RATE_LIMIT_API_KEY_HEADER", "X-API-Key"),
+        RateLimitAPIKeys:        splitList(os.Getenv("RATE_LIMIT_API_KEYS")),
+        RateLimitTrustedProxies: splitList(os.Getenv("RATE_LIMIT_TRUSTED_PROXIES")),
+        RateLimitIdleTTL:        rateLimitIdleTTL,
+        RateLimitMaxClients:     rateLimitMaxClients,
+        RateLimitMode:           getEnv("RATE_LIMIT_MODE", "reject"),
+        RateLimitQueueWait:      rateLimitQueueWait,
+        RateLimitQueueLength:    rateLimitQueueLength,
+        ProviderTimeout:   providerTimeout,
+        ProviderPriority:  splitList(os.Getenv("PROVIDER_PRIORITY")),
+        FetchMode:         getEnv("FETCH_MODE", "fanout"),
+        HedgeDelay:        hedgeDelay,
+        HedgePercentile:   hedgePercentile,
+        FetchLockTTL:      fetchLockTTL,
+        FetchLockWait:     fetchLockWait,
+        AggregationStrategy: getEnv("AGGREGATION_STRATEGY", "first"),
+        AggregationQuorum: aggregationQuorum,
+        ProviderWeights:   providerWeights,
+        OutlierTemperatureThreshold: outlierTemperature,
+        OutlierHumidityThreshold:    outlierHumidity,
+        BreakerFailureThreshold:     breakerFailures,
+        BreakerErrorRate:            breakerErrorRate,
+        BreakerMinRequests:          breakerMinRequests,
+        BreakerWindow:               breakerWindow,
+        BreakerCoolOff:              breakerCoolOff,
+        AdminToken:                  os.Getenv("ADMIN_TOKEN"),
+        RetryMaxAttempts:            retryMaxAttempts,
+        RetryBaseDelay:              retryBaseDelay,
+        RetryMaxDelay:               retryMaxDelay,
+        ProviderQuotas:              os.Getenv("PROVIDER_QUOTAS"),
+        QuotaReserve:                quotaReserve,
+        WarmPinnedCities:            splitList(os.Getenv("WARM_PINNED_CITIES")),
+        WarmTopN:                    warmTopN,
+        WarmMinRequests:             warmMinRequests,
+        WarmInterval:                warmInterval,
+        WarmRefreshAhead:            warmRefreshAhead,
+        WarmHalfLife:                warmHalfLife,
     }, nil
 }
 
@@ -42,4 +191,37 @@ func getEnv(key, defaultValue string) string {
         return value
     }
     return defaultValue
+}
+
+// parseProviderWeights parses "OpenWeatherMap=2,WeatherAPIMap=1" into a map
+// keyed by provider name.
+func parseProviderWeights(value string) (map[string]float64, error) {
+    weights := make(map[string]float64)
+    if value == "" {
+        return weights, nil
+    }
+
+    for _, pair := range strings.Split(value, ",") {
+        name, weight, ok := strings.Cut(pair, "=")
+        if !ok {
+            return nil, fmt.Errorf("invalid PROVIDER_WEIGHTS entry %q", pair)
+        }
+        parsed, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+        if err != nil {
+            return nil, fmt.Errorf("invalid PROVIDER_WEIGHTS weight for %q: %w", name, err)
+        }
+        weights[strings.TrimSpace(name)] = parsed
+    }
+    return weights, nil
+}
+
+// splitList splits a comma separated value, dropping empty entries.
+func splitList(value string) []string {
+    var items []string
+    for _, item := range strings.Split(value, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            items = append(items, item)
+        }
+    }
+    return items
 }
\ No newline at end of file
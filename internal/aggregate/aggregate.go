@@ -0,0 +1,166 @@
+package aggregate
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+// Strategy selects how results from several providers are fused into one.
+type Strategy string
+
+const (
+	// FirstWins returns whichever provider answers first.
+	FirstWins Strategy = "first"
+	// Median waits for every provider and takes the median reading.
+	Median Strategy = "median"
+	// WeightedMean waits for every provider and averages readings using
+	// per-provider weights.
+	WeightedMean Strategy = "weighted"
+	// Quorum waits for N providers and takes their median reading.
+	Quorum Strategy = "quorum"
+)
+
+// AggregatedSource is reported as the Source of a fused result; the
+// contributing providers are listed in Sources.
+const AggregatedSource = "aggregated"
+
+var (
+	ErrNoResults    = errors.New("no provider returned weather data")
+	ErrQuorumNotMet = errors.New("not enough providers answered to reach quorum")
+)
+
+func ParseStrategy(s string) (Strategy, error) {
+	switch strategy := Strategy(strings.ToLower(strings.TrimSpace(s))); strategy {
+	case FirstWins, Median, WeightedMean, Quorum:
+		return strategy, nil
+	case "":
+		return FirstWins, nil
+	default:
+		return "", fmt.Errorf("unknown aggregation strategy %q", s)
+	}
+}
+
+type Aggregator struct {
+	strategy   Strategy
+	weights    map[string]float64
+	quorum     int
+	thresholds Thresholds
+}
+
+// NewAggregator builds an Aggregator. weights is keyed by provider name and
+// only used by WeightedMean; providers without a weight count as 1. quorum is
+// only used by Quorum. Readings deviating from the median by more than
+// thresholds are excluded from fusion.
+func NewAggregator(strategy Strategy, weights map[string]float64, quorum int, thresholds Thresholds) *Aggregator {
+	if quorum < 1 {
+		quorum = 1
+	}
+	return &Aggregator{
+		strategy:   strategy,
+		weights:    weights,
+		quorum:     quorum,
+		thresholds: thresholds,
+	}
+}
+
+func (a *Aggregator) Strategy() Strategy {
+	return a.strategy
+}
+
+// Satisfied reports whether received results out of total queried providers
+// are enough to stop waiting for the rest.
+func (a *Aggregator) Satisfied(received, total int) bool {
+	switch a.strategy {
+	case FirstWins:
+		return received >= 1
+	case Quorum:
+		return received >= a.quorum || received >= total
+	default:
+		return received >= total
+	}
+}
+
+// Fuse combines the results into a single record. Results are expected in
+// provider priority order; non-numeric fields are taken from the first one,
+// so the most preferred provider that answered wins them. Outliers are left
+// out of the fused values and listed in the record's Outliers.
+func (a *Aggregator) Fuse(results []*models.WeatherData) (*models.WeatherData, error) {
+	if len(results) == 0 {
+		return nil, ErrNoResults
+	}
+
+	if a.strategy == FirstWins {
+		fused := *results[0]
+		fused.Sources = []string{fused.Source}
+		return &fused, nil
+	}
+
+	if a.strategy == Quorum && len(results) < a.quorum {
+		return nil, fmt.Errorf("%w: got %d of %d", ErrQuorumNotMet, len(results), a.quorum)
+	}
+
+	analysis := Analyze(results, a.thresholds)
+
+	fused := *analysis.Agreeing[0]
+	fused.Source = AggregatedSource
+	fused.Sources = make([]string, 0, len(analysis.Agreeing))
+	for _, result := range analysis.Agreeing {
+		fused.Sources = append(fused.Sources, result.Source)
+	}
+	for _, outlier := range analysis.Outliers {
+		fused.Outliers = append(fused.Outliers, outlier.Source)
+	}
+	if len(results) > 1 {
+		confidence := analysis.Confidence
+		fused.Confidence = &confidence
+	}
+
+	if a.strategy == WeightedMean {
+		fused.Temperature, fused.Humidity = a.weightedMean(analysis.Agreeing)
+	} else {
+		fused.Temperature, fused.Humidity = median(analysis.Agreeing)
+	}
+
+	return &fused, nil
+}
+
+func (a *Aggregator) weightedMean(results []*models.WeatherData) (float64, int) {
+	var temperature, humidity, total float64
+	for _, result := range results {
+		weight, ok := a.weights[result.Source]
+		if !ok {
+			weight = 1
+		}
+		temperature += weight * result.Temperature
+		humidity += weight * float64(result.Humidity)
+		total += weight
+	}
+	if total == 0 {
+		return median(results)
+	}
+	return temperature / total, int(math.Round(humidity / total))
+}
+
+func median(results []*models.WeatherData) (float64, int) {
+	temperatures := make([]float64, len(results))
+	humidities := make([]float64, len(results))
+	for i, result := range results {
+		temperatures[i] = result.Temperature
+		humidities[i] = float64(result.Humidity)
+	}
+	return medianOf(temperatures), int(math.Round(medianOf(humidities)))
+}
+
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[middle-1] + values[middle]) / 2
+	}
+	return values[middle]
+}
@@ -0,0 +1,69 @@
+package aggregate
+
+import (
+	"math"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+// Thresholds is how far a reading may deviate from the median of all
+// readings before it is considered an outlier. Zero disables the check for
+// that field.
+type Thresholds struct {
+	Temperature float64
+	Humidity    int
+}
+
+// Analysis is the result of comparing provider readings against each other.
+type Analysis struct {
+	Agreeing []*models.WeatherData
+	Outliers []*models.WeatherData
+	// Confidence is the share of results that agree with the consensus,
+	// from 0 to 1.
+	Confidence float64
+}
+
+// Analyze flags results that deviate from the median beyond thresholds.
+// At least three results are needed to tell which one is off; with two
+// disagreeing results neither is excluded and confidence is halved. When no
+// result is close to the median, all of them are kept with zero confidence.
+// Input order is preserved in Agreeing and Outliers.
+func Analyze(results []*models.WeatherData, thresholds Thresholds) Analysis {
+	switch len(results) {
+	case 0, 1:
+		return Analysis{Agreeing: results, Confidence: 1}
+	case 2:
+		if thresholds.deviates(results[1], results[0].Temperature, results[0].Humidity) {
+			return Analysis{Agreeing: results, Confidence: 0.5}
+		}
+		return Analysis{Agreeing: results, Confidence: 1}
+	}
+
+	medianTemperature, medianHumidity := median(results)
+
+	var analysis Analysis
+	for _, result := range results {
+		if thresholds.deviates(result, medianTemperature, medianHumidity) {
+			analysis.Outliers = append(analysis.Outliers, result)
+		} else {
+			analysis.Agreeing = append(analysis.Agreeing, result)
+		}
+	}
+
+	if len(analysis.Agreeing) == 0 {
+		return Analysis{Agreeing: results, Confidence: 0}
+	}
+
+	analysis.Confidence = float64(len(analysis.Agreeing)) / float64(len(results))
+	return analysis
+}
+
+func (t Thresholds) deviates(result *models.WeatherData, temperature float64, humidity int) bool {
+	if t.Temperature > 0 && math.Abs(result.Temperature-temperature) > t.Temperature {
+		return true
+	}
+	if t.Humidity > 0 && math.Abs(float64(result.Humidity-humidity)) > float64(t.Humidity) {
+		return true
+	}
+	return false
+}
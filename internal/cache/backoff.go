@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Backoff lets code that talks to Redis outside RedisCache, like the rate
+// limiter, fail fast while Redis is down: after a call fails to get an
+// answer, calls are skipped for a period instead of each waiting out its
+// timeout, and then a single call probes whether Redis is back.
+type Backoff struct {
+	period time.Duration
+	// until is when the next probe is due, in Unix nanoseconds, or zero
+	// while Redis is healthy.
+	until atomic.Int64
+}
+
+func NewBackoff(period time.Duration) *Backoff {
+	if period <= 0 {
+		period = healthCheckInterval
+	}
+	return &Backoff{period: period}
+}
+
+// Available reports whether a call should be sent to Redis.
+func (b *Backoff) Available() bool {
+	until := b.until.Load()
+	if until == 0 {
+		return true
+	}
+	now := time.Now()
+	// Let exactly one caller probe once the period is over
+	return now.UnixNano() >= until && b.until.CompareAndSwap(until, now.Add(b.period).UnixNano())
+}
+
+// Observe records the outcome of a call and returns err unchanged. Errors
+// Redis answered with don't count as failures, and neither does the caller
+// giving up, but timeouts do.
+func (b *Backoff) Observe(err error) error {
+	var redisErr redis.Error
+	switch {
+	case err == nil || errors.As(err, &redisErr):
+		b.until.Store(0)
+	case errors.Is(err, context.Canceled):
+	default:
+		b.until.Store(time.Now().Add(b.period).UnixNano())
+	}
+	return err
+}
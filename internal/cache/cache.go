@@ -5,56 +5,97 @@ package cache
 import (
 	"context"
 	"encoding/json"
-	"os"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/devonphone/weather-aggregator/internal/models"
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrNotFound is returned by Get when key holds a "location not found"
+// entry written by SetNotFound.
+var ErrNotFound = errors.New("cache: location not found")
+
 type Cache interface {
     Get(ctx context.Context, key string) (*models.WeatherData, error)
     Set(ctx context.Context, key string, value *models.WeatherData) error
+    // SetNotFound records that the location behind key does not exist, so
+    // that Get returns ErrNotFound for it until ttl passes.
+    SetNotFound(ctx context.Context, key string, ttl time.Duration) error
+    // Delete deletes key, returning how many keys were deleted: 0 if it did
+    // not exist.
+    Delete(ctx context.Context, key string) (int, error)
+
+    // Keys returns up to limit keys matching the glob pattern, sorted.
+    Keys(ctx context.Context, pattern string, limit int) ([]string, error)
+    // TTL returns how long key has left before it expires, or zero if it
+    // does not exist.
+    TTL(ctx context.Context, key string) (time.Duration, error)
+    // DeletePrefix deletes every key starting with prefix, returning how
+    // many were deleted.
+    DeletePrefix(ctx context.Context, prefix string) (int, error)
 }
 
+// notFoundMarker is stored in place of the JSON encoded weather data for
+// locations the providers don't know.
+const notFoundMarker = "not-found"
+
+// RedisCache stores entries in Redis. When Redis can't be reached it keeps
+// running in a degraded mode where every call fails fast with
+// ErrUnavailable, and reconnects in the background.
 type RedisCache struct {
-    client        *redis.Client
+    client        redis.UniversalClient
     cacheDuration time.Duration
+
+    healthy       atomic.Bool
+    healthMu      sync.Mutex
+    degradedSince time.Time
+    lastErr       error
+    done          chan struct{}
 }
 
-func NewRedisCache(cacheDuration time.Duration) (*RedisCache, error) {
-    redisAddr := os.Getenv("REDIS_ADDR")
-    redisUsername := os.Getenv("REDIS_USERNAME")
-    redisPassword := os.Getenv("REDIS_PASSWORD")
-
-    // Using your specific Redis connection details
-    client := redis.NewClient(&redis.Options{
-        Addr:     redisAddr,
-        Username: redisUsername,
-        Password: redisPassword,
-        DB:       0,
-    })
-
-    // Test the connection
-    ctx := context.Background()
-    _, err := client.Ping(ctx).Result()
+func NewRedisCache(cfg RedisConfig, cacheDuration time.Duration) (*RedisCache, error) {
+    client, err := NewRedisClient(cfg)
     if err != nil {
         return nil, err
     }
 
-    return &RedisCache{
+    c := &RedisCache{
         client:        client,
         cacheDuration: cacheDuration,
-    }, nil
+        done:          make(chan struct{}),
+    }
+    c.healthy.Store(true)
+
+    // Test the connection, but start degraded rather than failing when
+    // Redis is down
+    ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+    defer cancel()
+    if err := c.Ping(ctx); err != nil {
+        log.Printf("[WARN] Starting without a working Redis connection: %v", err)
+    }
+
+    go c.monitor()
+    return c, nil
 }
 
 func (c *RedisCache) Get(ctx context.Context, key string) (*models.WeatherData, error) {
+    if !c.available() {
+        return nil, ErrUnavailable
+    }
+
     val, err := c.client.Get(ctx, key).Result()
     if err == redis.Nil {
         return nil, nil
     }
     if err != nil {
-        return nil, err
+        return nil, c.observe(err)
+    }
+    if val == notFoundMarker {
+        return nil, ErrNotFound
     }
 
     var weather models.WeatherData
@@ -66,16 +107,44 @@ func (c *RedisCache) Get(ctx context.Context, key string) (*models.WeatherData,
     return &weather, nil
 }
 
+// Set stores value for the cache duration, stamping CachedAt unless the
+// value already carries one.
 func (c *RedisCache) Set(ctx context.Context, key string, value *models.WeatherData) error {
-    data, err := json.Marshal(value)
+    if !c.available() {
+        return ErrUnavailable
+    }
+
+    entry := *value
+    if entry.CachedAt == nil {
+        now := time.Now()
+        entry.CachedAt = &now
+    }
+
+    data, err := json.Marshal(entry)
     if err != nil {
         return err
     }
 
-    return c.client.Set(ctx, key, data, c.cacheDuration).Err()
+    return c.observe(c.client.Set(ctx, key, data, c.cacheDuration).Err())
+}
+
+func (c *RedisCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+    if !c.available() {
+        return ErrUnavailable
+    }
+    return c.observe(c.client.Set(ctx, key, notFoundMarker, ttl).Err())
+}
+
+func (c *RedisCache) Delete(ctx context.Context, key string) (int, error) {
+    if !c.available() {
+        return 0, ErrUnavailable
+    }
+    deleted, err := c.client.Del(ctx, key).Result()
+    return int(deleted), c.observe(err)
 }
 
 // Add a close method for proper cleanup
 func (c *RedisCache) Close() error {
+    close(c.done)
     return c.client.Close()
 }
\ No newline at end of file
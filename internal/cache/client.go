@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis deployment modes.
+const (
+	RedisStandalone = "standalone"
+	RedisSentinel   = "sentinel"
+	RedisCluster    = "cluster"
+)
+
+const defaultRedisAddr = "localhost:6379"
+
+// RedisConfig describes how to reach Redis.
+type RedisConfig struct {
+	// URL is a redis:// or rediss:// URL. Besides the address it carries
+	// the credentials, database index and, for rediss://, TLS, which also
+	// apply in Sentinel and Cluster mode.
+	URL string
+	// Addr, Username and Password are used when URL is empty.
+	Addr     string
+	Username string
+	Password string
+
+	// Mode is standalone (the default), sentinel or cluster.
+	Mode string
+	// Addrs lists the Sentinel addresses in sentinel mode and the seed
+	// nodes in cluster mode. In cluster mode it defaults to the URL's host.
+	Addrs []string
+	// SentinelMaster names the master set monitored by the Sentinels.
+	SentinelMaster   string
+	SentinelPassword string
+}
+
+// NewRedisClient builds a client for the configured deployment mode.
+func NewRedisClient(cfg RedisConfig) (redis.UniversalClient, error) {
+	opts := &redis.Options{
+		Addr:     cfg.Addr,
+		Username: cfg.Username,
+		Password: cfg.Password,
+	}
+	if cfg.URL != "" {
+		parsed, err := redis.ParseURL(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis URL: %w", err)
+		}
+		opts = parsed
+	}
+	if opts.Addr == "" {
+		opts.Addr = defaultRedisAddr
+	}
+
+	switch cfg.Mode {
+	case "", RedisStandalone:
+		return redis.NewClient(opts), nil
+	case RedisSentinel:
+		if cfg.SentinelMaster == "" || len(cfg.Addrs) == 0 {
+			return nil, errors.New("redis sentinel mode needs a master name and sentinel addresses")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       cfg.SentinelMaster,
+			SentinelAddrs:    cfg.Addrs,
+			SentinelPassword: cfg.SentinelPassword,
+			Username:         opts.Username,
+			Password:         opts.Password,
+			DB:               opts.DB,
+			TLSConfig:        opts.TLSConfig,
+		}), nil
+	case RedisCluster:
+		if opts.DB != 0 {
+			return nil, fmt.Errorf("redis cluster mode only supports database 0, got %d", opts.DB)
+		}
+		addrs := cfg.Addrs
+		if len(addrs) == 0 {
+			addrs = []string{opts.Addr}
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     addrs,
+			Username:  opts.Username,
+			Password:  opts.Password,
+			TLSConfig: opts.TLSConfig,
+		}), nil
+	default:
+		return nil, fmt.Errorf("invalid Redis mode %q, expected standalone, sentinel or cluster", cfg.Mode)
+	}
+}
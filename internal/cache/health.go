@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	healthCheckInterval = 5 * time.Second
+	healthCheckTimeout  = time.Second
+)
+
+// ErrUnavailable is returned by RedisCache while Redis is unreachable.
+// Callers should treat it as a cache bypass rather than a miss.
+var ErrUnavailable = errors.New("cache: redis is unavailable")
+
+// HealthChecker is implemented by caches backed by an external service that
+// can become unreachable.
+type HealthChecker interface {
+	Health() models.CacheHealth
+}
+
+// Health reports whether Redis is reachable and, if not, since when and why.
+func (c *RedisCache) Health() models.CacheHealth {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+
+	health := models.CacheHealth{Backend: "redis", Healthy: c.healthy.Load()}
+	if !health.Healthy {
+		since := c.degradedSince
+		health.DegradedSince = &since
+		if c.lastErr != nil {
+			health.Error = c.lastErr.Error()
+		}
+	}
+	return health
+}
+
+// Ping checks the connection to Redis and updates the health state
+// accordingly. It is called periodically in the background, so commands
+// resume shortly after Redis comes back.
+func (c *RedisCache) Ping(ctx context.Context) error {
+	err := c.client.Ping(ctx).Err()
+	c.setHealthy(err == nil, err)
+	return err
+}
+
+func (c *RedisCache) monitor() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+			c.Ping(ctx)
+			cancel()
+		}
+	}
+}
+
+// available reports whether commands should be sent to Redis. While it is
+// down they fail fast with ErrUnavailable instead of each waiting for a
+// connection attempt to time out.
+func (c *RedisCache) available() bool {
+	return c.healthy.Load()
+}
+
+// observe marks the cache degraded when err shows Redis could not be
+// reached, and returns err unchanged.
+func (c *RedisCache) observe(err error) error {
+	if connectionError(err) {
+		c.setHealthy(false, err)
+	}
+	return err
+}
+
+func (c *RedisCache) setHealthy(healthy bool, err error) {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+
+	if !healthy {
+		c.lastErr = err
+	}
+	if healthy == c.healthy.Load() {
+		return
+	}
+
+	if healthy {
+		log.Printf("[INFO] Redis is reachable again after %s, cache re-enabled", time.Since(c.degradedSince).Round(time.Second))
+	} else {
+		c.degradedSince = time.Now()
+		log.Printf("[WARN] Redis is unreachable, bypassing the cache: %v", err)
+	}
+	c.healthy.Store(healthy)
+}
+
+// connectionError reports whether err means Redis could not be reached, as
+// opposed to Redis answering with an error or the caller giving up.
+func connectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var redisErr redis.Error
+	return !errors.As(err, &redisErr)
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scanBatch is the COUNT hint for each SCAN call.
+const scanBatch = 500
+
+func (c *RedisCache) Keys(ctx context.Context, pattern string, limit int) ([]string, error) {
+	if !c.available() {
+		return nil, ErrUnavailable
+	}
+
+	var mu sync.Mutex
+	var keys []string
+	err := c.scan(ctx, pattern, func(batch []string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, key := range batch {
+			if len(keys) >= limit {
+				return false
+			}
+			keys = append(keys, key)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, c.observe(err)
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (c *RedisCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if !c.available() {
+		return 0, ErrUnavailable
+	}
+
+	ttl, err := c.client.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, c.observe(err)
+	}
+	// Redis reports missing keys and keys without expiry as negative
+	return max(ttl, 0), nil
+}
+
+func (c *RedisCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	if !c.available() {
+		return 0, ErrUnavailable
+	}
+
+	var mu sync.Mutex
+	var deleted int
+	var deleteErr error
+	err := c.scan(ctx, escapeGlob(prefix)+"*", func(batch []string) bool {
+		// Keys are unlinked one by one so that in cluster mode each goes to
+		// the node owning its slot
+		pipe := c.client.Pipeline()
+		for _, key := range batch {
+			pipe.Unlink(ctx, key)
+		}
+		cmds, err := pipe.Exec(ctx)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			deleteErr = err
+			return false
+		}
+		for _, cmd := range cmds {
+			deleted += int(cmd.(*redis.IntCmd).Val())
+		}
+		return true
+	})
+	if err == nil {
+		err = deleteErr
+	}
+	return deleted, c.observe(err)
+}
+
+// scan calls fn with batches of keys matching pattern until fn returns
+// false or every key was seen. In cluster mode every master is scanned, in
+// parallel, so fn must be safe for concurrent use.
+func (c *RedisCache) scan(ctx context.Context, pattern string, fn func(keys []string) bool) error {
+	scanNode := func(ctx context.Context, client *redis.Client) error {
+		var cursor uint64
+		for {
+			keys, next, err := client.Scan(ctx, cursor, pattern, scanBatch).Result()
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 && !fn(keys) {
+				return nil
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+
+	switch client := c.client.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(ctx, scanNode)
+	case *redis.Client:
+		return scanNode(ctx, client)
+	default:
+		return fmt.Errorf("cache: cannot scan keys with a %T", client)
+	}
+}
+
+// escapeGlob escapes the characters Redis treats specially in MATCH
+// patterns, so prefix is matched literally.
+func escapeGlob(prefix string) string {
+	var escaped strings.Builder
+	for _, r := range prefix {
+		if strings.ContainsRune(`*?[]\`, r) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Locker is implemented by caches shared between replicas. It lets one
+// replica take a short-lived lock on a key so that only it fetches the
+// value while the others wait for it to appear in the cache.
+type Locker interface {
+	// TryLock attempts to take the lock on key without blocking. When
+	// acquired, unlock must be called once the value has been cached; the
+	// lock expires on its own after ttl in case the holder dies.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (unlock func(), acquired bool, err error)
+}
+
+// LockSuffix is appended to a key to form the key of its fetch lock.
+const LockSuffix = ":lock"
+
+// IsLockKey reports whether key holds a fetch lock rather than an entry.
+func IsLockKey(key string) bool {
+	return strings.HasSuffix(key, LockSuffix)
+}
+
+// releaseLock deletes the lock only if it still holds our token, so a holder
+// whose lock already expired cannot release someone else's.
+var releaseLock = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+    return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func (c *RedisCache) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	if !c.available() {
+		return nil, false, ErrUnavailable
+	}
+
+	token, err := lockToken()
+	if err != nil {
+		return nil, false, err
+	}
+
+	lockKey := key + LockSuffix
+	acquired, err := c.client.SetNX(ctx, lockKey, token, ttl).Result()
+	if err != nil || !acquired {
+		return nil, false, c.observe(err)
+	}
+
+	unlock := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := releaseLock.Run(ctx, c.client, []string{lockKey}, token).Err(); err != nil {
+			log.Printf("[ERROR] Failed to release cache lock %s: %v", lockKey, err)
+		}
+	}
+	return unlock, true, nil
+}
+
+func lockToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
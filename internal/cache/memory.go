@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+// MemoryCache is an in-process, size-bounded LRU cache with a TTL. It needs
+// no external services, which makes it suitable for local runs and tests,
+// but entries are not shared between replicas.
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	entries  map[string]*list.Element
+	order    *list.List // front is most recently used
+}
+
+type memoryEntry struct {
+	key       string
+	value     models.WeatherData
+	notFound  bool
+	expiresAt time.Time
+}
+
+func NewMemoryCache(capacity int, ttl time.Duration) *MemoryCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &MemoryCache{
+		capacity: capacity,
+		ttl:      ttl,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) (*models.WeatherData, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, nil
+	}
+
+	entry := element.Value.(*memoryEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(element)
+		return nil, nil
+	}
+
+	c.order.MoveToFront(element)
+	if entry.notFound {
+		return nil, ErrNotFound
+	}
+	weather := entry.value
+	weather.Cached = true
+	return &weather, nil
+}
+
+// Set stores value for the cache TTL, stamping CachedAt unless the value
+// already carries one, and evicts the least recently used entries beyond
+// capacity.
+func (c *MemoryCache) Set(ctx context.Context, key string, value *models.WeatherData) error {
+	entry := &memoryEntry{
+		key:       key,
+		value:     *value,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	if entry.value.CachedAt == nil {
+		now := time.Now()
+		entry.value.CachedAt = &now
+	}
+
+	c.store(entry)
+	return nil
+}
+
+func (c *MemoryCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	c.store(&memoryEntry{
+		key:       key,
+		notFound:  true,
+		expiresAt: time.Now().Add(ttl),
+	})
+	return nil
+}
+
+// store inserts or replaces entry, evicting the least recently used entries
+// beyond capacity.
+func (c *MemoryCache) store(entry *memoryEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[entry.key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *MemoryCache) Delete(ctx context.Context, key string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return 0, nil
+	}
+	// Expired entries are dropped too, but like Redis they don't count
+	c.remove(element)
+	if time.Now().After(element.Value.(*memoryEntry).expiresAt) {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// Keys matches keys with path.Match, whose syntax is close to Redis' MATCH.
+func (c *MemoryCache) Keys(ctx context.Context, pattern string, limit int) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var keys []string
+	now := time.Now()
+	for key, element := range c.entries {
+		if now.After(element.Value.(*memoryEntry).expiresAt) {
+			continue
+		}
+		matched, err := path.Match(pattern, key)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys, nil
+}
+
+func (c *MemoryCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return 0, nil
+	}
+	return max(time.Until(element.Value.(*memoryEntry).expiresAt), 0), nil
+}
+
+func (c *MemoryCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	deleted := 0
+	now := time.Now()
+	for key, element := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(element)
+			if !now.After(element.Value.(*memoryEntry).expiresAt) {
+				deleted++
+			}
+		}
+	}
+	return deleted, nil
+}
+
+func (c *MemoryCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *MemoryCache) Close() error {
+	return nil
+}
+
+// remove drops element from the cache. Callers must hold c.mu.
+func (c *MemoryCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*memoryEntry).key)
+}
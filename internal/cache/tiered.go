@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+const invalidationChannel = "weather-aggregator:cache-invalidations"
+
+// TieredCache fronts a RedisCache (L2) with a short-lived in-process
+// MemoryCache (L1), so hot keys don't cost a Redis round-trip per request.
+// Writes and deletes are published on a Redis channel so that other
+// replicas drop their now outdated L1 copies.
+type TieredCache struct {
+	l1         *MemoryCache
+	l2         *RedisCache
+	instanceID string
+	pubsub     *redis.PubSub
+	done       chan struct{}
+}
+
+type invalidation struct {
+	Origin string `json:"origin"`
+	Key    string `json:"key"`
+	// Prefix invalidates every key starting with Key.
+	Prefix bool `json:"prefix,omitempty"`
+}
+
+func NewTieredCache(l2 *RedisCache, l1Capacity int, l1TTL time.Duration) (*TieredCache, error) {
+	instanceID, err := lockToken()
+	if err != nil {
+		return nil, err
+	}
+
+	pubsub := l2.client.Subscribe(context.Background(), invalidationChannel)
+	// Wait for the subscription to be confirmed so no invalidation is missed.
+	// If Redis is down the subscription is retried in the background; until
+	// then L1 entries are only bounded by their TTL.
+	if _, err := pubsub.Receive(context.Background()); err != nil {
+		log.Printf("[WARN] Failed to subscribe to cache invalidations, retrying in the background: %v", err)
+	}
+
+	c := &TieredCache{
+		l1:         NewMemoryCache(l1Capacity, l1TTL),
+		l2:         l2,
+		instanceID: instanceID,
+		pubsub:     pubsub,
+		done:       make(chan struct{}),
+	}
+	go c.listen()
+	return c, nil
+}
+
+func (c *TieredCache) Get(ctx context.Context, key string) (*models.WeatherData, error) {
+	if weather, err := c.l1.Get(ctx, key); err != nil || weather != nil {
+		return weather, err
+	}
+
+	weather, err := c.l2.Get(ctx, key)
+	if err != nil || weather == nil {
+		return weather, err
+	}
+
+	c.l1.Set(ctx, key, weather)
+	return weather, nil
+}
+
+func (c *TieredCache) Set(ctx context.Context, key string, value *models.WeatherData) error {
+	// Stamp once so both tiers agree on the entry's age
+	entry := *value
+	if entry.CachedAt == nil {
+		now := time.Now()
+		entry.CachedAt = &now
+	}
+
+	if err := c.l2.Set(ctx, key, &entry); err != nil {
+		return err
+	}
+	c.l1.Set(ctx, key, &entry)
+	c.publish(ctx, key)
+	return nil
+}
+
+func (c *TieredCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	if err := c.l2.SetNotFound(ctx, key, ttl); err != nil {
+		return err
+	}
+	c.l1.SetNotFound(ctx, key, min(ttl, c.l1.ttl))
+	c.publish(ctx, key)
+	return nil
+}
+
+func (c *TieredCache) Delete(ctx context.Context, key string) (int, error) {
+	c.l1.Delete(ctx, key)
+	deleted, err := c.l2.Delete(ctx, key)
+	if err != nil {
+		return deleted, err
+	}
+	c.publish(ctx, key)
+	return deleted, nil
+}
+
+func (c *TieredCache) Keys(ctx context.Context, pattern string, limit int) ([]string, error) {
+	return c.l2.Keys(ctx, pattern, limit)
+}
+
+func (c *TieredCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.l2.TTL(ctx, key)
+}
+
+func (c *TieredCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	c.l1.DeletePrefix(ctx, prefix)
+	deleted, err := c.l2.DeletePrefix(ctx, prefix)
+	if err != nil {
+		return deleted, err
+	}
+	c.publishInvalidation(ctx, invalidation{Origin: c.instanceID, Key: prefix, Prefix: true})
+	return deleted, nil
+}
+
+// Health reports the health of the Redis tier.
+func (c *TieredCache) Health() models.CacheHealth {
+	health := c.l2.Health()
+	health.Backend = "tiered"
+	return health
+}
+
+// TryLock takes the fetch lock in Redis, so locking still spans replicas.
+func (c *TieredCache) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	return c.l2.TryLock(ctx, key, ttl)
+}
+
+// Close stops listening for invalidations. The underlying RedisCache is
+// left open for its owner to close.
+func (c *TieredCache) Close() error {
+	err := c.pubsub.Close()
+	<-c.done
+	return err
+}
+
+func (c *TieredCache) publish(ctx context.Context, key string) {
+	c.publishInvalidation(ctx, invalidation{Origin: c.instanceID, Key: key})
+}
+
+func (c *TieredCache) publishInvalidation(ctx context.Context, inv invalidation) {
+	if !c.l2.available() {
+		return
+	}
+	message, err := json.Marshal(inv)
+	if err != nil {
+		return
+	}
+	if err := c.l2.client.Publish(ctx, invalidationChannel, message).Err(); err != nil {
+		log.Printf("[ERROR] Failed to publish cache invalidation for %s: %v", inv.Key, err)
+	}
+}
+
+func (c *TieredCache) listen() {
+	defer close(c.done)
+	for message := range c.pubsub.Channel() {
+		var inv invalidation
+		if err := json.Unmarshal([]byte(message.Payload), &inv); err != nil {
+			log.Printf("[ERROR] Ignoring malformed cache invalidation: %v", err)
+			continue
+		}
+		if inv.Origin == c.instanceID {
+			continue
+		}
+		if inv.Prefix {
+			c.l1.DeletePrefix(context.Background(), inv.Key)
+		} else {
+			c.l1.Delete(context.Background(), inv.Key)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package location
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// KeyVersion is part of every cache key. Bump it whenever the key format
+// changes so old entries are ignored instead of misread.
+const KeyVersion = "v2"
+
+// Namespace prefixes every key the aggregator writes, across key versions.
+const Namespace = "weather:"
+
+const keyPrefix = Namespace + KeyVersion + ":"
+
+var folder = cases.Fold()
+
+// Normalize returns the canonical spelling of a user supplied location:
+// Unicode NFKC normalized, case folded, trimmed and with inner whitespace
+// collapsed, so "Jakarta", "jakarta" and " JAKARTA " compare equal.
+func Normalize(city string) string {
+	normalized := folder.String(norm.NFKC.String(city))
+	return strings.Join(strings.Fields(normalized), " ")
+}
+
+// QueryKey is the cache key for a location as the user typed it.
+func QueryKey(city string) string {
+	return keyPrefix + "q:" + Normalize(city)
+}
+
+// ResolvedKey is the cache key for the location a provider resolved a query
+// to, built from its coordinates rounded to about a kilometre. Names are not
+// used: "Springfield, United States of America" is several places. ok is
+// false when the data carries no coordinates.
+func ResolvedKey(data *models.WeatherData) (key string, ok bool) {
+	if data.Coordinates == nil {
+		return "", false
+	}
+	return fmt.Sprintf("%sgeo:%.2f,%.2f", keyPrefix, data.Coordinates.Latitude, data.Coordinates.Longitude), true
+}
+
+// Resolver remembers which canonical location each query resolved to, so
+// different spellings of the same place share one cache entry once any of
+// them has been fetched. The alias table is in-process and bounded.
+type Resolver struct {
+	mu       sync.RWMutex
+	aliases  map[string]string
+	capacity int
+}
+
+func NewResolver(capacity int) *Resolver {
+	return &Resolver{
+		aliases:  make(map[string]string),
+		capacity: capacity,
+	}
+}
+
+// Key returns the cache key for city: the resolved location's key if the
+// query has been seen before, otherwise its query key.
+func (r *Resolver) Key(city string) string {
+	queryKey := QueryKey(city)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if resolved, ok := r.aliases[queryKey]; ok {
+		return resolved
+	}
+	return queryKey
+}
+
+// Learn records what city resolved to and returns every key the data should
+// be cached under: the query key, so replicas that have not learned the
+// alias yet still find it, and the resolved key if there is one.
+func (r *Resolver) Learn(city string, data *models.WeatherData) []string {
+	queryKey := QueryKey(city)
+	resolved, ok := ResolvedKey(data)
+	if !ok || resolved == queryKey {
+		return []string{queryKey}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, known := r.aliases[queryKey]; !known && len(r.aliases) >= r.capacity {
+		// Drop an arbitrary alias; it is re-learned on the next fetch
+		for key := range r.aliases {
+			delete(r.aliases, key)
+			break
+		}
+	}
+	r.aliases[queryKey] = resolved
+	return []string{queryKey, resolved}
+}
@@ -4,14 +4,27 @@ import "time"
 
 type WeatherData struct {
     City        string    `json:"city"`
+    Country     string    `json:"country,omitempty"`
+    Coordinates *Coordinates `json:"coordinates,omitempty"`
     Temperature float64   `json:"temperature"`
     Humidity    int       `json:"humidity"`
     Condition   string    `json:"condition"`
     Source      string    `json:"source"`
+    Sources     []string  `json:"sources,omitempty"`
+    Outliers    []string  `json:"outliers,omitempty"`
+    Confidence  *float64  `json:"confidence,omitempty"`
     Cached      bool      `json:"cached"`
+    CachedAt    *time.Time `json:"cached_at,omitempty"`
+    Stale       bool      `json:"stale,omitempty"`
+    AgeSeconds  int64     `json:"age_seconds,omitempty"`
     Timestamp   time.Time `json:"timestamp"`
 }
 
+type Coordinates struct {
+    Latitude  float64 `json:"lat"`
+    Longitude float64 `json:"lon"`
+}
+
 type ErrorResponse struct {
     Error   string `json:"error"`
     Code    int    `json:"code"`
@@ -24,4 +37,49 @@ type StatsResponse struct {
     CacheMisses    int64 `json:"cache_misses"`
     ApiCalls       int64 `json:"api_calls"`
     RateLimitHits  int64 `json:"rate_limit_hits"`
-}
\ No newline at end of file
+    RateLimitQueued int64 `json:"rate_limit_queued"`
+    CoalescedRequests int64 `json:"coalesced_requests"`
+    RemoteCoalescedRequests int64 `json:"remote_coalesced_requests"`
+    StaleHits      int64 `json:"stale_hits"`
+    StaleIfErrorHits int64 `json:"stale_if_error_hits"`
+    NegativeCacheHits int64 `json:"negative_cache_hits"`
+    CacheErrors    int64 `json:"cache_errors"`
+    Providers      map[string]ProviderStats `json:"providers,omitempty"`
+}
+
+type ProviderStats struct {
+    Outliers        int64  `json:"outliers"`
+    BreakerState    string `json:"breaker_state,omitempty"`
+    BudgetExhausted int64  `json:"budget_exhausted"`
+}
+
+type HealthResponse struct {
+    Status string      `json:"status"`
+    Cache  CacheHealth `json:"cache"`
+}
+
+type CacheHealth struct {
+    Backend       string     `json:"backend"`
+    Healthy       bool       `json:"healthy"`
+    DegradedSince *time.Time `json:"degraded_since,omitempty"`
+    Error         string     `json:"error,omitempty"`
+}
+
+type CacheKeysResponse struct {
+    Keys      []string `json:"keys"`
+    Truncated bool     `json:"truncated"`
+}
+
+type CacheEntryResponse struct {
+    Key        string       `json:"key"`
+    TTLSeconds int64        `json:"ttl_seconds"`
+    NotFound   bool         `json:"not_found,omitempty"`
+    // Lock is set for the fetch lock a replica holds while it fetches Key
+    // without the suffix.
+    Lock       bool         `json:"lock,omitempty"`
+    Data       *WeatherData `json:"data,omitempty"`
+}
+
+type CachePurgeResponse struct {
+    Deleted int `json:"deleted"`
+}
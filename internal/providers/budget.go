@@ -0,0 +1,47 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+// ErrBudgetExhausted is returned instead of calling a provider whose call
+// budget is (nearly) spent. It is a quota error, so callers treat it like
+// the upstream's own 429, but the circuit breaker ignores it: the provider
+// itself is healthy.
+var ErrBudgetExhausted = fmt.Errorf("%w: call budget exhausted", ErrQuotaExceeded)
+
+// Budget accounts for calls to providers with limited plans.
+type Budget interface {
+	// Spend records a call to provider, or returns an error wrapping
+	// ErrBudgetExhausted if the call would exceed its budget.
+	Spend(ctx context.Context, provider string) error
+}
+
+// BudgetedProvider wraps a WeatherProvider and only calls it while its
+// budget allows, so that once a plan's cap is nearly reached the provider
+// is skipped and the others take over.
+type BudgetedProvider struct {
+	provider WeatherProvider
+	budget   Budget
+}
+
+func NewBudgetedProvider(provider WeatherProvider, budget Budget) *BudgetedProvider {
+	return &BudgetedProvider{
+		provider: provider,
+		budget:   budget,
+	}
+}
+
+func (p *BudgetedProvider) GetWeather(ctx context.Context, city string) (*models.WeatherData, error) {
+	if err := p.budget.Spend(ctx, p.provider.GetProviderName()); err != nil {
+		return nil, err
+	}
+	return p.provider.GetWeather(ctx, city)
+}
+
+func (p *BudgetedProvider) GetProviderName() string {
+	return p.provider.GetProviderName()
+}
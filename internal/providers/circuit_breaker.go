@@ -0,0 +1,207 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+var ErrCircuitOpen = fmt.Errorf("%w: circuit breaker is open", ErrUpstreamUnavailable)
+
+type CircuitBreakerConfig struct {
+	// FailureThreshold consecutive failures open the breaker.
+	FailureThreshold int
+	// ErrorRateThreshold opens the breaker when the share of failed calls in
+	// the current window reaches it, once MinRequests calls were made.
+	ErrorRateThreshold float64
+	MinRequests        int
+	Window             time.Duration
+	// CoolOff is how long the breaker stays open before letting a single
+	// probe call through.
+	CoolOff time.Duration
+	// OnStateChange, if set, is called after every state transition. It
+	// runs with the breaker locked and must not call back into it.
+	OnStateChange func(provider string, from, to BreakerState)
+}
+
+// BreakerStatus is a point-in-time view of a CircuitBreaker.
+type BreakerStatus struct {
+	Provider            string       `json:"provider"`
+	State               BreakerState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	WindowRequests      int          `json:"window_requests"`
+	WindowFailures      int          `json:"window_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+}
+
+// CircuitBreaker wraps a WeatherProvider so that a provider that keeps
+// failing stops being called for a cool-off period. It is closed while the
+// provider is healthy, open while calls are rejected with ErrCircuitOpen and
+// half-open while a single probe call decides whether to close again.
+type CircuitBreaker struct {
+	provider WeatherProvider
+	config   CircuitBreakerConfig
+
+	mu                  sync.Mutex
+	state               BreakerState
+	consecutiveFailures int
+	windowStart         time.Time
+	windowRequests      int
+	windowFailures      int
+	openedAt            time.Time
+	probing             bool
+}
+
+func NewCircuitBreaker(provider WeatherProvider, config CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		provider:    provider,
+		config:      config,
+		state:       BreakerClosed,
+		windowStart: time.Now(),
+	}
+}
+
+func (b *CircuitBreaker) GetWeather(ctx context.Context, city string) (*models.WeatherData, error) {
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	data, err := b.provider.GetWeather(ctx, city)
+	b.record(err)
+	return data, err
+}
+
+func (b *CircuitBreaker) GetProviderName() string {
+	return b.provider.GetProviderName()
+}
+
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		Provider:            b.provider.GetProviderName(),
+		State:               b.state,
+		ConsecutiveFailures: b.consecutiveFailures,
+		WindowRequests:      b.windowRequests,
+		WindowFailures:      b.windowFailures,
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// allow reports whether a call may go through, moving an open breaker to
+// half-open once the cool-off has passed.
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.config.CoolOff {
+			return false
+		}
+		b.transition(BreakerHalfOpen)
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *CircuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// The caller gave up on the call, or it was never made because our own
+	// budget ran out, which says nothing about the provider
+	if errors.Is(err, context.Canceled) || errors.Is(err, ErrBudgetExhausted) {
+		b.probing = false
+		return
+	}
+
+	if b.config.Window > 0 && time.Since(b.windowStart) >= b.config.Window {
+		b.windowStart = time.Now()
+		b.windowRequests = 0
+		b.windowFailures = 0
+	}
+	b.windowRequests++
+
+	// An unknown location is the caller's mistake, not a provider failure
+	if err == nil || errors.Is(err, ErrLocationNotFound) {
+		b.consecutiveFailures = 0
+		if b.state == BreakerHalfOpen {
+			b.probing = false
+			b.transition(BreakerClosed)
+		}
+		return
+	}
+
+	b.windowFailures++
+	b.consecutiveFailures++
+
+	if b.state == BreakerHalfOpen {
+		b.probing = false
+		b.open()
+		return
+	}
+
+	if b.config.FailureThreshold > 0 && b.consecutiveFailures >= b.config.FailureThreshold {
+		b.open()
+		return
+	}
+
+	if b.config.ErrorRateThreshold > 0 && b.windowRequests >= b.config.MinRequests {
+		rate := float64(b.windowFailures) / float64(b.windowRequests)
+		if rate >= b.config.ErrorRateThreshold {
+			b.open()
+		}
+	}
+}
+
+// open trips the breaker. Callers must hold b.mu.
+func (b *CircuitBreaker) open() {
+	if b.state == BreakerOpen {
+		return
+	}
+	b.openedAt = time.Now()
+	b.transition(BreakerOpen)
+}
+
+// transition changes state and notifies the listener. Callers must hold b.mu.
+func (b *CircuitBreaker) transition(to BreakerState) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	if to == BreakerClosed {
+		b.windowStart = time.Now()
+		b.windowRequests = 0
+		b.windowFailures = 0
+	}
+	if b.config.OnStateChange != nil {
+		b.config.OnStateChange(b.provider.GetProviderName(), from, to)
+	}
+}
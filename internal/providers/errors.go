@@ -0,0 +1,132 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provider errors are classified into these sentinels so callers can tell
+// failures apart with errors.Is.
+var (
+	ErrLocationNotFound    = errors.New("location not found")
+	ErrUnauthorized        = errors.New("provider rejected credentials")
+	ErrQuotaExceeded       = errors.New("provider quota exceeded")
+	ErrUpstreamUnavailable = errors.New("provider unavailable")
+	ErrBadResponse         = errors.New("provider returned an invalid response")
+)
+
+// HTTPError is returned when an upstream API answers with a non-200 status.
+// It unwraps to one of the sentinel errors above.
+type HTTPError struct {
+	API        string
+	StatusCode int
+	// Message is the upstream's own error description, if it sent one.
+	Message string
+	// RetryAfter is the delay requested by the upstream's Retry-After
+	// header, or zero if it sent none.
+	RetryAfter time.Duration
+	// Kind overrides the sentinel derived from StatusCode, for APIs that
+	// report the actual reason in the response body.
+	Kind error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s API error: %d (%s)", e.API, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s API error: %d", e.API, e.StatusCode)
+}
+
+func (e *HTTPError) Unwrap() error {
+	if e.Kind != nil {
+		return e.Kind
+	}
+	switch {
+	case e.StatusCode == http.StatusNotFound:
+		return ErrLocationNotFound
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrUnauthorized
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrQuotaExceeded
+	case e.StatusCode >= 500:
+		return ErrUpstreamUnavailable
+	default:
+		return ErrBadResponse
+	}
+}
+
+func newHTTPError(api string, resp *http.Response) *HTTPError {
+	return &HTTPError{
+		API:        api,
+		StatusCode: resp.StatusCode,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// unavailable wraps a transport error so it matches ErrUpstreamUnavailable
+// while keeping the original error reachable with errors.As.
+func unavailable(err error) error {
+	return fmt.Errorf("%w: %w", ErrUpstreamUnavailable, err)
+}
+
+// badResponse wraps a decoding error so it matches ErrBadResponse.
+func badResponse(err error) error {
+	return fmt.Errorf("%w: %w", ErrBadResponse, err)
+}
+
+// parseRetryAfter accepts both forms of the Retry-After header: a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(at); delay > 0 {
+			return delay
+		}
+	}
+	return 0
+}
+
+// ProviderError records why a single provider failed to return weather data.
+type ProviderError struct {
+	Provider string
+	Err      error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Provider + ": " + e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// FetchError is returned when no usable result could be obtained from any
+// provider. It lists every provider's failure reason.
+type FetchError struct {
+	Errors []*ProviderError
+}
+
+func (e *FetchError) Error() string {
+	reasons := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		reasons = append(reasons, err.Error())
+	}
+	return "all providers failed: " + strings.Join(reasons, "; ")
+}
+
+func (e *FetchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
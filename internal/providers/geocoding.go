@@ -0,0 +1,87 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const OpenMeteoGeocodingURL = "https://geocoding-api.open-meteo.com/v1/search"
+
+// Location is a place name resolved to coordinates.
+type Location struct {
+	Name      string
+	Country   string
+	Latitude  float64
+	Longitude float64
+}
+
+// Geocoder resolves a free-form city name to a Location. Providers whose
+// upstream only accepts coordinates use it before fetching weather.
+type Geocoder interface {
+	Geocode(ctx context.Context, city string) (*Location, error)
+}
+
+// OpenMeteoGeocoder uses the keyless Open-Meteo geocoding API.
+type OpenMeteoGeocoder struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewOpenMeteoGeocoder(baseURL string) *OpenMeteoGeocoder {
+	return &OpenMeteoGeocoder{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (g *OpenMeteoGeocoder) Geocode(ctx context.Context, city string) (*Location, error) {
+	query := url.Values{}
+	query.Set("name", city)
+	query.Set("count", "1")
+	query.Set("language", "en")
+	query.Set("format", "json")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", g.baseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError("Open-Meteo geocoding", resp)
+	}
+
+	var result struct {
+		Results []struct {
+			Name      string  `json:"name"`
+			Country   string  `json:"country"`
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+		} `json:"results"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, badResponse(err)
+	}
+
+	if len(result.Results) == 0 {
+		return nil, fmt.Errorf("%w: Open-Meteo geocoding found no match for %q", ErrLocationNotFound, city)
+	}
+
+	match := result.Results[0]
+	return &Location{
+		Name:      match.Name,
+		Country:   match.Country,
+		Latitude:  match.Latitude,
+		Longitude: match.Longitude,
+	}, nil
+}
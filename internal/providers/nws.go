@@ -0,0 +1,253 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+const (
+	NWSBaseURL = "https://api.weather.gov"
+
+	// Forecast offices and stations practically never move, so a resolved
+	// gridpoint can be reused for a long time.
+	nwsGridpointTTL = 24 * time.Hour
+	// nwsGridpointCapacity bounds the gridpoint cache, which is keyed by
+	// whatever users type.
+	nwsGridpointCapacity = 10000
+)
+
+// nwsGridpoint is what the two-step /points lookup resolves a city to.
+type nwsGridpoint struct {
+	name      string
+	country   string
+	latitude  float64
+	longitude float64
+	office    string
+	gridX     int
+	gridY     int
+	stationID string
+	// err is set instead of the fields above for locations NWS does not
+	// cover, so they aren't looked up again on every request.
+	err       error
+	expiresAt time.Time
+}
+
+// NWSProvider reads the latest station observation from the US National
+// Weather Service (api.weather.gov). The API only covers US locations and
+// requires a User-Agent identifying the caller.
+type NWSProvider struct {
+	baseURL   string
+	userAgent string
+	geocoder  Geocoder
+	client    *http.Client
+
+	mu         sync.RWMutex
+	gridpoints map[string]*nwsGridpoint
+}
+
+func NewNWSProvider(userAgent string) *NWSProvider {
+	return NewNWSProviderWithURLs(NWSBaseURL, OpenMeteoGeocodingURL, userAgent)
+}
+
+// NewNWSProviderWithURLs points the provider at alternative api.weather.gov
+// and geocoding endpoints.
+func NewNWSProviderWithURLs(baseURL, geocodingURL, userAgent string) *NWSProvider {
+	return &NWSProvider{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		userAgent:  userAgent,
+		geocoder:   NewOpenMeteoGeocoder(geocodingURL),
+		client:     &http.Client{Timeout: 10 * time.Second},
+		gridpoints: make(map[string]*nwsGridpoint),
+	}
+}
+
+func (p *NWSProvider) GetWeather(ctx context.Context, city string) (*models.WeatherData, error) {
+	gridpoint, err := p.resolveGridpoint(ctx, city)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Properties struct {
+			TextDescription string `json:"textDescription"`
+			Temperature     struct {
+				Value *float64 `json:"value"`
+			} `json:"temperature"`
+			RelativeHumidity struct {
+				Value *float64 `json:"value"`
+			} `json:"relativeHumidity"`
+		} `json:"properties"`
+	}
+
+	url := fmt.Sprintf("%s/stations/%s/observations/latest", p.baseURL, gridpoint.stationID)
+	if err := p.getJSON(ctx, url, &result); err != nil {
+		return nil, err
+	}
+
+	if result.Properties.Temperature.Value == nil {
+		return nil, fmt.Errorf("%w: NWS station %s reported no temperature", ErrBadResponse, gridpoint.stationID)
+	}
+
+	humidity := 0
+	if result.Properties.RelativeHumidity.Value != nil {
+		humidity = int(math.Round(*result.Properties.RelativeHumidity.Value))
+	}
+
+	return &models.WeatherData{
+		City:        gridpoint.name,
+		Country:     gridpoint.country,
+		Coordinates: &models.Coordinates{Latitude: gridpoint.latitude, Longitude: gridpoint.longitude},
+		Temperature: *result.Properties.Temperature.Value,
+		Humidity:    humidity,
+		Condition:   result.Properties.TextDescription,
+		Source:      p.GetProviderName(),
+		Timestamp:   time.Now(),
+	}, nil
+}
+
+func (p *NWSProvider) GetProviderName() string {
+	return "NationalWeatherService"
+}
+
+// resolveGridpoint maps a city to its forecast office, grid coordinates and
+// nearest observation station, caching the result so the /points round-trip
+// is only paid once per city. Cities outside NWS coverage are cached too.
+func (p *NWSProvider) resolveGridpoint(ctx context.Context, city string) (*nwsGridpoint, error) {
+	key := strings.ToLower(strings.TrimSpace(city))
+
+	p.mu.RLock()
+	gridpoint, ok := p.gridpoints[key]
+	p.mu.RUnlock()
+	if ok && time.Now().Before(gridpoint.expiresAt) {
+		if gridpoint.err != nil {
+			return nil, gridpoint.err
+		}
+		return gridpoint, nil
+	}
+
+	gridpoint, err := p.lookupGridpoint(ctx, city)
+	if errors.Is(err, ErrLocationNotFound) {
+		gridpoint = &nwsGridpoint{err: err}
+	} else if err != nil {
+		return nil, err
+	}
+	gridpoint.expiresAt = time.Now().Add(nwsGridpointTTL)
+
+	p.mu.Lock()
+	p.storeGridpoint(key, gridpoint)
+	p.mu.Unlock()
+
+	if gridpoint.err != nil {
+		return nil, gridpoint.err
+	}
+	return gridpoint, nil
+}
+
+// lookupGridpoint resolves city through the geocoder and the /points and
+// stations endpoints.
+func (p *NWSProvider) lookupGridpoint(ctx context.Context, city string) (*nwsGridpoint, error) {
+	location, err := p.geocoder.Geocode(ctx, city)
+	if err != nil {
+		return nil, err
+	}
+
+	var point struct {
+		Properties struct {
+			GridID string `json:"gridId"`
+			GridX  int    `json:"gridX"`
+			GridY  int    `json:"gridY"`
+		} `json:"properties"`
+	}
+
+	// api.weather.gov rejects coordinates with more than four decimals.
+	url := fmt.Sprintf("%s/points/%.4f,%.4f", p.baseURL, location.Latitude, location.Longitude)
+	if err := p.getJSON(ctx, url, &point); err != nil {
+		return nil, err
+	}
+
+	var stations struct {
+		Features []struct {
+			Properties struct {
+				StationIdentifier string `json:"stationIdentifier"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+
+	url = fmt.Sprintf(
+		"%s/gridpoints/%s/%d,%d/stations",
+		p.baseURL, point.Properties.GridID, point.Properties.GridX, point.Properties.GridY,
+	)
+	if err := p.getJSON(ctx, url, &stations); err != nil {
+		return nil, err
+	}
+
+	if len(stations.Features) == 0 {
+		return nil, fmt.Errorf("%w: NWS gridpoint %s/%d,%d has no observation stations", ErrBadResponse,
+			point.Properties.GridID, point.Properties.GridX, point.Properties.GridY)
+	}
+
+	return &nwsGridpoint{
+		name:      location.Name,
+		country:   location.Country,
+		latitude:  location.Latitude,
+		longitude: location.Longitude,
+		office:    point.Properties.GridID,
+		gridX:     point.Properties.GridX,
+		gridY:     point.Properties.GridY,
+		stationID: stations.Features[0].Properties.StationIdentifier,
+	}, nil
+}
+
+// storeGridpoint caches gridpoint under key. When the cache is full, expired
+// entries are dropped first, then arbitrary ones; they are resolved again on
+// the next request. Callers must hold p.mu.
+func (p *NWSProvider) storeGridpoint(key string, gridpoint *nwsGridpoint) {
+	if _, known := p.gridpoints[key]; !known && len(p.gridpoints) >= nwsGridpointCapacity {
+		now := time.Now()
+		for cached, entry := range p.gridpoints {
+			if !now.Before(entry.expiresAt) {
+				delete(p.gridpoints, cached)
+			}
+		}
+		for cached := range p.gridpoints {
+			if len(p.gridpoints) < nwsGridpointCapacity {
+				break
+			}
+			delete(p.gridpoints, cached)
+		}
+	}
+	p.gridpoints[key] = gridpoint
+}
+
+func (p *NWSProvider) getJSON(ctx context.Context, url string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", p.userAgent)
+	req.Header.Set("Accept", "application/geo+json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return unavailable(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPError("NWS", resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return badResponse(err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+const OpenMeteoForecastURL = "https://api.open-meteo.com/v1/forecast"
+
+// wmoConditions maps WMO weather interpretation codes, as returned in
+// Open-Meteo's weather_code field, to human readable conditions.
+var wmoConditions = map[int]string{
+	0:  "Clear sky",
+	1:  "Mainly clear",
+	2:  "Partly cloudy",
+	3:  "Overcast",
+	45: "Fog",
+	48: "Depositing rime fog",
+	51: "Light drizzle",
+	53: "Moderate drizzle",
+	55: "Dense drizzle",
+	56: "Light freezing drizzle",
+	57: "Dense freezing drizzle",
+	61: "Slight rain",
+	63: "Moderate rain",
+	65: "Heavy rain",
+	66: "Light freezing rain",
+	67: "Heavy freezing rain",
+	71: "Slight snow fall",
+	73: "Moderate snow fall",
+	75: "Heavy snow fall",
+	77: "Snow grains",
+	80: "Slight rain showers",
+	81: "Moderate rain showers",
+	82: "Violent rain showers",
+	85: "Slight snow showers",
+	86: "Heavy snow showers",
+	95: "Thunderstorm",
+	96: "Thunderstorm with slight hail",
+	99: "Thunderstorm with heavy hail",
+}
+
+// WMOCondition returns the description for a WMO weather code.
+func WMOCondition(code int) string {
+	if condition, ok := wmoConditions[code]; ok {
+		return condition
+	}
+	return "Unknown"
+}
+
+// OpenMeteoProvider talks to the keyless Open-Meteo current weather API.
+// Open-Meteo only accepts coordinates, so cities are geocoded first.
+type OpenMeteoProvider struct {
+	forecastURL string
+	geocoder    Geocoder
+	client      *http.Client
+}
+
+func NewOpenMeteoProvider() *OpenMeteoProvider {
+	return NewOpenMeteoProviderWithURLs(OpenMeteoForecastURL, OpenMeteoGeocodingURL)
+}
+
+// NewOpenMeteoProviderWithURLs points the provider at alternative forecast and
+// geocoding endpoints, e.g. a self-hosted Open-Meteo instance.
+func NewOpenMeteoProviderWithURLs(forecastURL, geocodingURL string) *OpenMeteoProvider {
+	return &OpenMeteoProvider{
+		forecastURL: forecastURL,
+		geocoder:    NewOpenMeteoGeocoder(geocodingURL),
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *OpenMeteoProvider) GetWeather(ctx context.Context, city string) (*models.WeatherData, error) {
+	location, err := p.geocoder.Geocode(ctx, city)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("latitude", strconv.FormatFloat(location.Latitude, 'f', -1, 64))
+	query.Set("longitude", strconv.FormatFloat(location.Longitude, 'f', -1, 64))
+	query.Set("current", "temperature_2m,relative_humidity_2m,weather_code")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.forecastURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, unavailable(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError("Open-Meteo", resp)
+	}
+
+	var result struct {
+		Current struct {
+			Temperature float64 `json:"temperature_2m"`
+			Humidity    float64 `json:"relative_humidity_2m"`
+			WeatherCode int     `json:"weather_code"`
+		} `json:"current"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, badResponse(err)
+	}
+
+	return &models.WeatherData{
+		City:        location.Name,
+		Country:     location.Country,
+		Coordinates: &models.Coordinates{Latitude: location.Latitude, Longitude: location.Longitude},
+		Temperature: result.Current.Temperature,
+		Humidity:    int(math.Round(result.Current.Humidity)),
+		Condition:   WMOCondition(result.Current.WeatherCode),
+		Source:      p.GetProviderName(),
+		Timestamp:   time.Now(),
+	}, nil
+}
+
+func (p *OpenMeteoProvider) GetProviderName() string {
+	return "OpenMeteo"
+}
@@ -6,43 +6,65 @@ import (
     "fmt"
     "github.com/devonphone/weather-aggregator/internal/models"
     "net/http"
+    "net/url"
     "time"
 )
 
+const OpenWeatherBaseURL = "https://api.openweathermap.org/data/2.5/weather"
+
 type OpenWeatherProvider struct {
-    apiKey string
-    client *http.Client
+    apiKey  string
+    baseURL string
+    client  *http.Client
 }
 
 func NewOpenWeatherProvider(apiKey string) *OpenWeatherProvider {
+    return NewOpenWeatherProviderWithURL(apiKey, OpenWeatherBaseURL)
+}
+
+// NewOpenWeatherProviderWithURL points the provider at an alternative
+// current weather endpoint.
+func NewOpenWeatherProviderWithURL(apiKey, baseURL string) *OpenWeatherProvider {
     return &OpenWeatherProvider{
-        apiKey: apiKey,
-        client: &http.Client{Timeout: 10 * time.Second},
+        apiKey:  apiKey,
+        baseURL: baseURL,
+        client:  &http.Client{Timeout: 10 * time.Second},
     }
 }
 
 func (p *OpenWeatherProvider) GetWeather(ctx context.Context, city string) (*models.WeatherData, error) {
-    url := fmt.Sprintf(
-        "https://api.openweathermap.org/data/2.5/weather?q=%s&appid=%s&units=metric",
-        city, p.apiKey,
-    )
+    query := url.Values{}
+    query.Set("q", city)
+    query.Set("appid", p.apiKey)
+    query.Set("units", "metric")
 
-    req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+    req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"?"+query.Encode(), nil)
     if err != nil {
         return nil, err
     }
 
     resp, err := p.client.Do(req)
     if err != nil {
-        return nil, err
+        return nil, unavailable(err)
     }
     defer resp.Body.Close()
 
     if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("OpenWeather API error: %d", resp.StatusCode)
+        httpErr := newHTTPError("OpenWeather", resp)
+        var body struct {
+            Message string `json:"message"`
+        }
+        if json.NewDecoder(resp.Body).Decode(&body) == nil {
+            httpErr.Message = body.Message
+        }
+        return nil, httpErr
     }
 
     var result struct {
+        Coord struct {
+            Lat float64 `json:"lat"`
+            Lon float64 `json:"lon"`
+        } `json:"coord"`
         Main struct {
             Temp     float64 `json:"temp"`
             Humidity int     `json:"humidity"`
@@ -53,11 +75,18 @@ func (p *OpenWeatherProvider) GetWeather(ctx context.Context, city string) (*mod
     }
 
     if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-        return nil, err
+        return nil, badResponse(err)
+    }
+
+    if len(result.Weather) == 0 {
+        return nil, fmt.Errorf("%w: OpenWeather response has no weather conditions", ErrBadResponse)
     }
 
+    // OpenWeather only reports ISO country codes while the other providers
+    // report country names, so the location is identified by coordinates
     return &models.WeatherData{
         City:        city,
+        Coordinates: &models.Coordinates{Latitude: result.Coord.Lat, Longitude: result.Coord.Lon},
         Temperature: result.Main.Temp,
         Humidity:    result.Main.Humidity,
         Condition:   result.Weather[0].Description,
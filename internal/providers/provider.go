@@ -2,10 +2,36 @@ package providers
 
 import (
     "context"
+    "sort"
     "github.com/devonphone/weather-aggregator/internal/models"
 )
 
 type WeatherProvider interface {
     GetWeather(ctx context.Context, city string) (*models.WeatherData, error)
     GetProviderName() string
-}
\ No newline at end of file
+}
+
+// SortByPriority orders providers by their position in priority, which lists
+// provider names. Providers not listed keep their relative order after the
+// listed ones.
+func SortByPriority(providers []WeatherProvider, priority []string) []WeatherProvider {
+    rank := make(map[string]int, len(priority))
+    for i, name := range priority {
+        rank[name] = i
+    }
+
+    sorted := make([]WeatherProvider, len(providers))
+    copy(sorted, providers)
+    sort.SliceStable(sorted, func(i, j int) bool {
+        ri, ok := rank[sorted[i].GetProviderName()]
+        if !ok {
+            ri = len(priority)
+        }
+        rj, ok := rank[sorted[j].GetProviderName()]
+        if !ok {
+            rj = len(priority)
+        }
+        return ri < rj
+    })
+    return sorted
+}
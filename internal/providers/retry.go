@@ -0,0 +1,120 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+type RetryConfig struct {
+	// MaxAttempts is the total number of calls, including the first one.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// RetryingProvider wraps a WeatherProvider and retries transient failures
+// (timeouts, connection resets, 429 and 502/503/504) with capped exponential
+// backoff and jitter. It never sleeps past the context deadline. When the
+// context has a deadline, each attempt gets an equal share of the time left
+// for the remaining attempts, so a hung attempt times out early enough to be
+// retried.
+type RetryingProvider struct {
+	provider WeatherProvider
+	config   RetryConfig
+}
+
+func NewRetryingProvider(provider WeatherProvider, config RetryConfig) *RetryingProvider {
+	if config.MaxAttempts < 1 {
+		config.MaxAttempts = 1
+	}
+	return &RetryingProvider{
+		provider: provider,
+		config:   config,
+	}
+}
+
+func (p *RetryingProvider) GetWeather(ctx context.Context, city string) (*models.WeatherData, error) {
+	for attempt := 1; ; attempt++ {
+		attemptCtx, cancel := p.attemptContext(ctx, attempt)
+		data, err := p.provider.GetWeather(attemptCtx, city)
+		timedOut := attemptCtx.Err() != nil
+		cancel()
+		if err == nil || attempt >= p.config.MaxAttempts || ctx.Err() != nil || !(timedOut || isTransient(err)) {
+			return data, err
+		}
+
+		delay := p.backoff(attempt)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > delay {
+			delay = httpErr.RetryAfter
+		}
+
+		// Give up now rather than wake up after the caller stopped waiting
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return data, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return data, err
+		}
+	}
+}
+
+func (p *RetryingProvider) GetProviderName() string {
+	return p.provider.GetProviderName()
+}
+
+// attemptContext bounds an attempt to its share of the time left until
+// ctx's deadline. The last attempt gets all of it.
+func (p *RetryingProvider) attemptContext(ctx context.Context, attempt int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok || attempt >= p.config.MaxAttempts {
+		return context.WithCancel(ctx)
+	}
+	remaining := p.config.MaxAttempts - attempt + 1
+	return context.WithTimeout(ctx, time.Until(deadline)/time.Duration(remaining))
+}
+
+// backoff returns the delay before the next attempt: BaseDelay doubled for
+// every previous attempt, capped at MaxDelay, with the upper half jittered.
+func (p *RetryingProvider) backoff(attempt int) time.Duration {
+	delay := p.config.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.config.MaxDelay > 0 && delay > p.config.MaxDelay) {
+		delay = p.config.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// isTransient reports whether err is worth retrying.
+func isTransient(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
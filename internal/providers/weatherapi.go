@@ -3,43 +3,64 @@ package providers
 import (
     "context"
     "encoding/json"
-    "fmt"
     "github.com/devonphone/weather-aggregator/internal/models"
     "net/http"
+    "net/url"
     "time"
 )
 
+const WeatherAPIBaseURL = "http://api.weatherapi.com/v1/current.json"
+
 type WeatherAPIProvider struct {
-    apiKey string
-    client *http.Client
+    apiKey  string
+    baseURL string
+    client  *http.Client
 }
 
 func NewWeatherAPIProvider(apiKey string) *WeatherAPIProvider {
+    return NewWeatherAPIProviderWithURL(apiKey, WeatherAPIBaseURL)
+}
+
+// NewWeatherAPIProviderWithURL points the provider at an alternative
+// current weather endpoint.
+func NewWeatherAPIProviderWithURL(apiKey, baseURL string) *WeatherAPIProvider {
     return &WeatherAPIProvider{
-        apiKey: apiKey,
-        client: &http.Client{Timeout: 10 * time.Second},
+        apiKey:  apiKey,
+        baseURL: baseURL,
+        client:  &http.Client{Timeout: 10 * time.Second},
     }
 }
 
 func (p *WeatherAPIProvider) GetWeather(ctx context.Context, city string) (*models.WeatherData, error) {
-	url := fmt.Sprintf(
-		"http://api.weatherapi.com/v1/current.json?key=%s&q=%s&aqi=no", 
-		p.apiKey, city,
-	)
+    query := url.Values{}
+    query.Set("key", p.apiKey)
+    query.Set("q", city)
+    query.Set("aqi", "no")
 
-    req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+    req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"?"+query.Encode(), nil)
     if err != nil {
         return nil, err
     }
 
     resp, err := p.client.Do(req)
     if err != nil {
-        return nil, err
+        return nil, unavailable(err)
     }
     defer resp.Body.Close()
 
     if resp.StatusCode != http.StatusOK {
-        return nil, fmt.Errorf("WeatherAPI API error: %d", resp.StatusCode)
+        httpErr := newHTTPError("WeatherAPI", resp)
+        var body struct {
+            Error struct {
+                Code    int    `json:"code"`
+                Message string `json:"message"`
+            } `json:"error"`
+        }
+        if json.NewDecoder(resp.Body).Decode(&body) == nil {
+            httpErr.Message = body.Error.Message
+            httpErr.Kind = weatherAPIErrorKind(body.Error.Code)
+        }
+        return nil, httpErr
     }
 
 	var result struct {
@@ -47,6 +68,8 @@ func (p *WeatherAPIProvider) GetWeather(ctx context.Context, city string) (*mode
             Name    string `json:"name"`
             Region  string `json:"region"`
             Country string `json:"country"`
+            Lat     float64 `json:"lat"`
+            Lon     float64 `json:"lon"`
         } `json:"location"`
         Current struct {
             TempC      float64 `json:"temp_c"`
@@ -58,11 +81,13 @@ func (p *WeatherAPIProvider) GetWeather(ctx context.Context, city string) (*mode
     }
 
     if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-        return nil, err
+        return nil, badResponse(err)
     }
 
     return &models.WeatherData{
         City:        result.Location.Name,
+        Country:     result.Location.Country,
+        Coordinates: &models.Coordinates{Latitude: result.Location.Lat, Longitude: result.Location.Lon},
         Temperature: result.Current.TempC,
         Humidity:    result.Current.Humidity,
         Condition:   result.Current.Condition.Text,
@@ -73,4 +98,22 @@ func (p *WeatherAPIProvider) GetWeather(ctx context.Context, city string) (*mode
 
 func (p *WeatherAPIProvider) GetProviderName() string {
     return "WeatherAPIMap"
+}
+
+// weatherAPIErrorKind maps WeatherAPI's error codes to provider errors. It
+// reports 400 for unknown locations and 403 for exhausted quotas, so the
+// status code alone is not enough.
+func weatherAPIErrorKind(code int) error {
+    switch code {
+    case 1006:
+        return ErrLocationNotFound
+    case 1002, 2006, 2008, 2009:
+        return ErrUnauthorized
+    case 2007:
+        return ErrQuotaExceeded
+    case 9999:
+        return ErrUpstreamUnavailable
+    default:
+        return nil
+    }
 }
\ No newline at end of file
@@ -0,0 +1,170 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/internal/cache"
+	"github.com/devonphone/weather-aggregator/internal/providers"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyPrefix = "weather-aggregator:quota:"
+	timeout   = time.Second
+	// backoff is how long Redis is skipped after it failed to answer.
+	backoff = 5 * time.Second
+)
+
+// Period is a calendar window, in UTC, that a provider plan caps calls in.
+type Period string
+
+const (
+	Minute Period = "minute"
+	Day    Period = "day"
+	Month  Period = "month"
+)
+
+// Limits caps a provider's calls per period. Periods without a cap are
+// left out.
+type Limits map[Period]int
+
+// ParseLimits parses "OpenWeatherMap:minute=60,day=1000;WeatherAPIMap:month=1000000"
+// into limits keyed by provider name.
+func ParseLimits(value string) (map[string]Limits, error) {
+	limits := make(map[string]Limits)
+	for _, entry := range strings.Split(value, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		provider, caps, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid quota entry %q, expected provider:period=calls", entry)
+		}
+
+		providerLimits := make(Limits)
+		for _, pair := range strings.Split(caps, ",") {
+			period, calls, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid quota %q for %s", pair, provider)
+			}
+			switch Period(strings.TrimSpace(period)) {
+			case Minute, Day, Month:
+			default:
+				return nil, fmt.Errorf("invalid quota period %q for %s, expected minute, day or month", period, provider)
+			}
+			parsed, err := strconv.Atoi(strings.TrimSpace(calls))
+			if err != nil || parsed < 1 {
+				return nil, fmt.Errorf("invalid quota calls %q for %s", calls, provider)
+			}
+			providerLimits[Period(strings.TrimSpace(period))] = parsed
+		}
+		limits[strings.TrimSpace(provider)] = providerLimits
+	}
+	return limits, nil
+}
+
+// spend checks every window of a provider and, only if none is used up,
+// counts the call in all of them. Returns the 1-based index of the first
+// window that is used up, or 0 when the call was counted.
+var spend = redis.NewScript(`
+local n = #KEYS
+for i = 1, n do
+    local used = tonumber(redis.call("GET", KEYS[i]) or "0")
+    if used >= tonumber(ARGV[i]) then
+        return i
+    end
+end
+for i = 1, n do
+    if redis.call("INCR", KEYS[i]) == 1 then
+        redis.call("PEXPIRE", KEYS[i], ARGV[n + i])
+    end
+end
+return 0
+`)
+
+// RedisBudget counts provider calls per calendar minute, day and month in
+// Redis, so the count is shared by every replica and survives restarts. A
+// provider's budget is exhausted once all but the reserve fraction of any
+// of its caps is used, keeping some headroom for calls already in flight
+// and for other users of the same API key. Providers without limits are
+// never restricted, and when Redis is down calls are allowed without
+// waiting on it.
+type RedisBudget struct {
+	client  redis.Scripter
+	limits  map[string]Limits
+	reserve float64
+	backoff *cache.Backoff
+}
+
+func NewRedisBudget(client redis.Scripter, limits map[string]Limits, reserve float64) *RedisBudget {
+	return &RedisBudget{
+		client:  client,
+		limits:  limits,
+		reserve: reserve,
+		backoff: cache.NewBackoff(backoff),
+	}
+}
+
+func (b *RedisBudget) Spend(ctx context.Context, provider string) error {
+	limits := b.limits[provider]
+	if len(limits) == 0 || !b.backoff.Available() {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	var periods []Period
+	var keys []string
+	var budgets, ttls []interface{}
+	for _, period := range []Period{Minute, Day, Month} {
+		calls, ok := limits[period]
+		if !ok {
+			continue
+		}
+		start, end := window(period, now)
+		periods = append(periods, period)
+		// The hash tag keeps a provider's keys in one cluster slot
+		keys = append(keys, fmt.Sprintf("%s{%s}:%s:%d", keyPrefix, provider, period, start.Unix()))
+		budgets = append(budgets, b.budget(calls))
+		ttls = append(ttls, end.Sub(now).Milliseconds()+time.Minute.Milliseconds())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	exhausted, err := spend.Run(ctx, b.client, keys, append(budgets, ttls...)...).Int()
+	if b.backoff.Observe(err) != nil {
+		log.Printf("[ERROR] Quota budget unavailable, allowing call to %s: %v", provider, err)
+		return nil
+	}
+	if exhausted > 0 {
+		period := periods[exhausted-1]
+		return fmt.Errorf("%w: %s has used its %s budget of %d calls", providers.ErrBudgetExhausted, provider, period, budgets[exhausted-1])
+	}
+	return nil
+}
+
+// budget is the number of calls out of a cap that may be spent, keeping the
+// reserve, but always at least one.
+func (b *RedisBudget) budget(calls int) int {
+	return max(int(float64(calls)*(1-b.reserve)), 1)
+}
+
+// window returns the start and end of the period containing now.
+func window(period Period, now time.Time) (time.Time, time.Time) {
+	switch period {
+	case Minute:
+		start := now.Truncate(time.Minute)
+		return start, start.Add(time.Minute)
+	case Day:
+		start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1)
+	default:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+}
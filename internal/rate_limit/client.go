@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIdentifier derives the identity a request is rate limited under:
+// its API key when it sends a known one, otherwise its IP address.
+type ClientIdentifier struct {
+	apiKeyHeader   string
+	apiKeys        map[string]bool
+	trustedProxies []*net.IPNet
+}
+
+// NewClientIdentifier reads API keys from apiKeyHeader, accepting only those
+// in apiKeys, and trusts X-Forwarded-For only on requests coming from
+// trustedProxies, given as IP addresses or CIDR ranges. Unknown keys are
+// ignored rather than given their own limit, or a client could get a fresh
+// one with every request just by making keys up.
+func NewClientIdentifier(apiKeyHeader string, apiKeys, trustedProxies []string) (*ClientIdentifier, error) {
+	identifier := &ClientIdentifier{
+		apiKeyHeader: apiKeyHeader,
+		apiKeys:      make(map[string]bool, len(apiKeys)),
+	}
+	for _, apiKey := range apiKeys {
+		identifier.apiKeys[hashKey(apiKey)] = true
+	}
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", proxy, err)
+		}
+		identifier.trustedProxies = append(identifier.trustedProxies, network)
+	}
+	return identifier, nil
+}
+
+// Identify returns "key:<hash>" for requests carrying a known API key,
+// hashed so keys are not kept around in the clear, and "ip:<address>"
+// otherwise.
+func (c *ClientIdentifier) Identify(r *http.Request) string {
+	if c.apiKeyHeader != "" {
+		if apiKey := r.Header.Get(c.apiKeyHeader); apiKey != "" {
+			if hashed := hashKey(apiKey); c.apiKeys[hashed] {
+				return "key:" + hashed
+			}
+		}
+	}
+	return "ip:" + c.clientIP(r)
+}
+
+func hashKey(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:16])
+}
+
+// clientIP returns the address of the peer, or, when the peer is a trusted
+// proxy, the right-most X-Forwarded-For address that isn't one. Entries left
+// of it may have been made up by the client.
+func (c *ClientIdentifier) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !c.trusted(host) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		host = hop
+		if !c.trusted(hop) {
+			break
+		}
+	}
+	return host
+}
+
+func (c *ClientIdentifier) trusted(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range c.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
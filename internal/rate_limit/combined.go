@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Combined applies several limits at once, e.g. a client's own bucket and a
+// global cap, allowing a request only if every limit does. Every limit is
+// checked before any is taken, so a request refused by one limit does not
+// use up the others.
+type Combined []RateLimiter
+
+func (c Combined) Allow() bool {
+	return c.Take().Allowed
+}
+
+// Wait blocks until every limit allows a request at once and takes it from
+// all of them. Like RedisLimiter.Wait, it gives up early with
+// ErrWaitExceedsDeadline when the wait would outlast ctx's deadline.
+func (c Combined) Wait(ctx context.Context) error {
+	for {
+		status := c.Take()
+		if status.Allowed {
+			return nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < status.RetryAfter {
+			return ErrWaitExceedsDeadline
+		}
+
+		timer := time.NewTimer(status.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c Combined) Take() Status {
+	if status := c.Status(); !status.Allowed {
+		return status
+	}
+
+	// Another request may have got in since the check above; the limits
+	// taken before the one that refuses are then charged regardless.
+	var status Status
+	for i, limiter := range c {
+		taken := limiter.Take()
+		if i == 0 || tighter(taken, status) {
+			status = taken
+		}
+		if !taken.Allowed {
+			status.Allowed = false
+			return status
+		}
+	}
+	return status
+}
+
+func (c Combined) Status() Status {
+	var status Status
+	allowed := true
+	for i, limiter := range c {
+		current := limiter.Status()
+		allowed = allowed && current.Allowed
+		if i == 0 || tighter(current, status) {
+			status = current
+		}
+	}
+	status.Allowed = allowed
+	return status
+}
+
+// tighter reports whether a leaves a client less room than b.
+func tighter(a, b Status) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if a.RetryAfter != b.RetryAfter {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const (
+	defaultIdleTTL    = 10 * time.Minute
+	defaultMaxClients = 10000
+)
+
+// Keyed hands out a RateLimiter per client identity.
+type Keyed interface {
+	Limiter(key string) RateLimiter
+}
+
+// KeyedLimiter keeps an in-memory token bucket per client, so one noisy
+// client only exhausts its own bucket. Buckets unused for the idle TTL are
+// dropped; as long as the TTL is at least the rate limit duration, a dropped
+// bucket would have refilled anyway. At most maxClients buckets are kept,
+// dropping the least recently used one to make room, so memory stays
+// bounded however many clients show up between sweeps.
+type KeyedLimiter struct {
+	requests   int
+	duration   time.Duration
+	idleTTL    time.Duration
+	maxClients int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	order   *list.List // front is most recently used
+}
+
+type keyedBucket struct {
+	key      string
+	limiter  *TokenBucketLimiter
+	lastSeen time.Time
+}
+
+func NewKeyedLimiter(requests int, duration, idleTTL time.Duration, maxClients int) *KeyedLimiter {
+	if idleTTL <= 0 {
+		idleTTL = defaultIdleTTL
+	}
+	if maxClients <= 0 {
+		maxClients = defaultMaxClients
+	}
+	return &KeyedLimiter{
+		requests:   requests,
+		duration:   duration,
+		idleTTL:    idleTTL,
+		maxClients: maxClients,
+		buckets:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Limiter returns the bucket for key, creating a full one if needed.
+func (k *KeyedLimiter) Limiter(key string) RateLimiter {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	k.sweep(now)
+
+	if element, ok := k.buckets[key]; ok {
+		bucket := element.Value.(*keyedBucket)
+		bucket.lastSeen = now
+		k.order.MoveToFront(element)
+		return bucket.limiter
+	}
+
+	if k.order.Len() >= k.maxClients {
+		k.remove(k.order.Back())
+	}
+	bucket := &keyedBucket{
+		key:      key,
+		limiter:  NewTokenBucketLimiter(k.requests, k.duration),
+		lastSeen: now,
+	}
+	k.buckets[key] = k.order.PushFront(bucket)
+	return bucket.limiter
+}
+
+// Len returns the number of tracked clients.
+func (k *KeyedLimiter) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.buckets)
+}
+
+// sweep drops buckets idle for longer than the idle TTL, starting from the
+// least recently used. Callers must hold k.mu.
+func (k *KeyedLimiter) sweep(now time.Time) {
+	for element := k.order.Back(); element != nil; element = k.order.Back() {
+		if now.Sub(element.Value.(*keyedBucket).lastSeen) < k.idleTTL {
+			return
+		}
+		k.remove(element)
+	}
+}
+
+// remove drops a bucket. Callers must hold k.mu.
+func (k *KeyedLimiter) remove(element *list.Element) {
+	k.order.Remove(element)
+	delete(k.buckets, element.Value.(*keyedBucket).key)
+}
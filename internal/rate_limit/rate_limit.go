@@ -2,6 +2,7 @@ package ratelimit
 
 import (
     "context"
+    "math"
     "golang.org/x/time/rate"
 
     "time"
@@ -10,6 +11,22 @@ import (
 type RateLimiter interface {
     Allow() bool
     Wait(context.Context) error
+    // Take is Allow that also reports the state of the limit afterwards.
+    Take() Status
+    // Status reports the state of the limit without using up a request.
+    Status() Status
+}
+
+// Status describes a rate limit, as reported in RateLimit-* headers.
+type Status struct {
+    Allowed   bool
+    Limit     int
+    Remaining int
+    // Reset is how long until the full limit is available again.
+    Reset time.Duration
+    // RetryAfter is how long until the next request is allowed, zero if it
+    // is allowed now.
+    RetryAfter time.Duration
 }
 
 type TokenBucketLimiter struct {
@@ -28,4 +45,33 @@ func (t *TokenBucketLimiter) Allow() bool {
 
 func (t *TokenBucketLimiter) Wait(ctx context.Context) error {
     return t.limiter.Wait(ctx)
-}
\ No newline at end of file
+}
+
+func (t *TokenBucketLimiter) Take() Status {
+    now := time.Now()
+    allowed := t.limiter.AllowN(now, 1)
+    status := t.statusAt(now)
+    status.Allowed = allowed
+    return status
+}
+
+func (t *TokenBucketLimiter) Status() Status {
+    return t.statusAt(time.Now())
+}
+
+func (t *TokenBucketLimiter) statusAt(now time.Time) Status {
+    tokens := t.limiter.TokensAt(now)
+    burst := float64(t.limiter.Burst())
+    perToken := float64(time.Second) / float64(t.limiter.Limit())
+
+    status := Status{
+        Allowed:   tokens >= 1,
+        Limit:     t.limiter.Burst(),
+        Remaining: int(math.Max(math.Floor(tokens), 0)),
+        Reset:     time.Duration((burst - tokens) * perToken),
+    }
+    if tokens < 1 {
+        status.RetryAfter = time.Duration((1 - tokens) * perToken)
+    }
+    return status
+}
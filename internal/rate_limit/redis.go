@@ -0,0 +1,161 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/internal/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrWaitExceedsDeadline is returned by RedisLimiter.Wait when the next
+// request is allowed too late for the caller's deadline.
+var ErrWaitExceedsDeadline = errors.New("rate limit wait would exceed context deadline")
+
+const (
+	redisKeyPrefix = "weather-aggregator:ratelimit:"
+	redisTimeout   = time.Second
+	// redisBackoff is how long Redis is skipped after it failed to answer.
+	redisBackoff = 5 * time.Second
+)
+
+// gcra implements the generic cell rate algorithm. It stores a single
+// "theoretical arrival time" per key: a request is allowed when that time is
+// no further in the future than the burst tolerance, and moves it one
+// emission interval ahead. Times come from the Redis clock, so replicas
+// with skewed clocks still agree, and are in microseconds. With ARGV[3]
+// set to 0 the limit is only inspected.
+//
+// Returns {allowed, retry after, remaining requests, reset}.
+var gcra = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local tolerance = tonumber(ARGV[2])
+local take = ARGV[3] == "1"
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000000 + tonumber(time[2])
+
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat < now then
+    tat = now
+end
+
+local allowed = 0
+local allow_at = tat - tolerance
+if now >= allow_at then
+    allowed = 1
+    if take then
+        tat = tat + interval
+        redis.call("SET", KEYS[1], tat, "PX", math.ceil((tat - now) / 1000))
+        allow_at = tat - tolerance
+    end
+end
+
+local remaining = 0
+if now >= allow_at then
+    remaining = math.floor((now - allow_at) / interval) + 1
+end
+return {allowed, math.max(allow_at - now, 0), remaining, tat - now}
+`)
+
+// RedisLimiter enforces a rate limit shared by every replica, using an
+// atomic GCRA script in Redis. It allows bursts of up to the full number of
+// requests, like TokenBucketLimiter. Keys expire once their bucket would be
+// full again, so idle clients cost nothing. When Redis can't be reached
+// requests are allowed rather than failing the service along with the cache.
+type RedisLimiter struct {
+	client    redis.Scripter
+	key       string
+	limit     int
+	interval  time.Duration
+	tolerance time.Duration
+	backoff   *cache.Backoff
+}
+
+// NewRedisLimiter limits name to requests per duration.
+func NewRedisLimiter(client redis.Scripter, name string, requests int, duration time.Duration) *RedisLimiter {
+	interval := duration / time.Duration(requests)
+	return &RedisLimiter{
+		client:    client,
+		key:       redisKeyPrefix + name,
+		limit:     requests,
+		interval:  interval,
+		tolerance: interval * time.Duration(requests-1),
+		backoff:   cache.NewBackoff(redisBackoff),
+	}
+}
+
+// Limiter returns a limiter with the same rate for the client key, so a
+// RedisLimiter also serves as a Keyed limiter.
+func (l *RedisLimiter) Limiter(key string) RateLimiter {
+	limiter := *l
+	limiter.key = l.key + ":" + key
+	return &limiter
+}
+
+func (l *RedisLimiter) Allow() bool {
+	return l.Take().Allowed
+}
+
+func (l *RedisLimiter) Take() Status {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return l.run(ctx, true)
+}
+
+func (l *RedisLimiter) Status() Status {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	return l.run(ctx, false)
+}
+
+// Wait blocks until a request is allowed or ctx is done. Like
+// rate.Limiter, it gives up right away when the next request is only
+// allowed after ctx's deadline.
+func (l *RedisLimiter) Wait(ctx context.Context) error {
+	for {
+		status := l.run(ctx, true)
+		if status.Allowed {
+			return nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < status.RetryAfter {
+			return ErrWaitExceedsDeadline
+		}
+
+		timer := time.NewTimer(status.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// run runs the GCRA script, taking a request when take is set. While Redis
+// is failing, requests are allowed without asking it.
+func (l *RedisLimiter) run(ctx context.Context, take bool) Status {
+	if !l.backoff.Available() {
+		return Status{Allowed: true, Limit: l.limit, Remaining: l.limit}
+	}
+
+	flag := 0
+	if take {
+		flag = 1
+	}
+	result, err := gcra.Run(ctx, l.client, []string{l.key},
+		l.interval.Microseconds(), l.tolerance.Microseconds(), flag).Int64Slice()
+	if l.backoff.Observe(err) != nil {
+		log.Printf("[ERROR] Rate limiter unavailable, allowing request: %v", err)
+		return Status{Allowed: true, Limit: l.limit, Remaining: l.limit}
+	}
+	return Status{
+		Allowed:    result[0] == 1,
+		Limit:      l.limit,
+		Remaining:  int(result[2]),
+		RetryAfter: time.Duration(result[1]) * time.Microsecond,
+		Reset:      time.Duration(result[3]) * time.Microsecond,
+	}
+}
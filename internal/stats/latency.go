@@ -0,0 +1,65 @@
+package stats
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	latencyWindow     = 100
+	latencyMinSamples = 20
+)
+
+// LatencyTracker keeps the most recent successful call durations per
+// provider so callers can derive percentiles from them.
+type LatencyTracker struct {
+	mu      sync.Mutex
+	samples map[string]*latencyRing
+}
+
+type latencyRing struct {
+	values []time.Duration
+	next   int
+}
+
+func NewLatencyTracker() *LatencyTracker {
+	return &LatencyTracker{
+		samples: make(map[string]*latencyRing),
+	}
+}
+
+func (t *LatencyTracker) Observe(provider string, latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	ring, ok := t.samples[provider]
+	if !ok {
+		ring = &latencyRing{values: make([]time.Duration, 0, latencyWindow)}
+		t.samples[provider] = ring
+	}
+	if len(ring.values) < latencyWindow {
+		ring.values = append(ring.values, latency)
+		return
+	}
+	ring.values[ring.next] = latency
+	ring.next = (ring.next + 1) % latencyWindow
+}
+
+// Percentile returns the p-th percentile (0-100) of the provider's recent
+// latencies. ok is false until enough samples were observed.
+func (t *LatencyTracker) Percentile(provider string, p float64) (latency time.Duration, ok bool) {
+	t.mu.Lock()
+	ring, found := t.samples[provider]
+	if !found || len(ring.values) < latencyMinSamples {
+		t.mu.Unlock()
+		return 0, false
+	}
+	values := make([]time.Duration, len(ring.values))
+	copy(values, ring.values)
+	t.mu.Unlock()
+
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	index := int(p / 100 * float64(len(values)-1))
+	return values[index], true
+}
@@ -1,6 +1,7 @@
 package stats
 
 import (
+    "sync"
     "sync/atomic"
     "github.com/devonphone/weather-aggregator/internal/models"
 )
@@ -11,10 +12,22 @@ type StatsTracker struct {
     cacheMisses    int64
     apiCalls       int64
     rateLimitHits  int64
+    rateLimitQueued int64
+    coalesced      int64
+    remoteCoalesced int64
+    staleHits      int64
+    staleIfError   int64
+    negativeHits   int64
+    cacheErrors    int64
+
+    mu        sync.Mutex
+    providers map[string]*models.ProviderStats
 }
 
 func NewStatsTracker() *StatsTracker {
-    return &StatsTracker{}
+    return &StatsTracker{
+        providers: make(map[string]*models.ProviderStats),
+    }
 }
 
 func (s *StatsTracker) IncrementRequests() {
@@ -37,12 +50,103 @@ func (s *StatsTracker) IncrementRateLimitHits() {
     atomic.AddInt64(&s.rateLimitHits, 1)
 }
 
+// IncrementRateLimitQueued counts a request over the rate limit that was
+// served after waiting in the queue instead of being rejected.
+func (s *StatsTracker) IncrementRateLimitQueued() {
+    atomic.AddInt64(&s.rateLimitQueued, 1)
+}
+
+// IncrementCoalescedRequests counts a cache miss that was served by joining
+// another request's in-flight provider fetch.
+func (s *StatsTracker) IncrementCoalescedRequests() {
+    atomic.AddInt64(&s.coalesced, 1)
+}
+
+// IncrementRemoteCoalescedRequests counts a cache miss that was served by
+// waiting for another replica's provider fetch.
+func (s *StatsTracker) IncrementRemoteCoalescedRequests() {
+    atomic.AddInt64(&s.remoteCoalesced, 1)
+}
+
+// IncrementStaleHits counts a stale entry served while it is refreshed in
+// the background.
+func (s *StatsTracker) IncrementStaleHits() {
+    atomic.AddInt64(&s.staleHits, 1)
+}
+
+// IncrementStaleIfErrorHits counts a stale entry served because every
+// provider failed.
+func (s *StatsTracker) IncrementStaleIfErrorHits() {
+    atomic.AddInt64(&s.staleIfError, 1)
+}
+
+// IncrementNegativeCacheHits counts a request for an unknown location that
+// was answered from the cache without calling the providers.
+func (s *StatsTracker) IncrementNegativeCacheHits() {
+    atomic.AddInt64(&s.negativeHits, 1)
+}
+
+// IncrementCacheErrors counts a cache operation that failed, e.g. because
+// Redis is down. Failed lookups are counted here instead of as misses.
+func (s *StatsTracker) IncrementCacheErrors() {
+    atomic.AddInt64(&s.cacheErrors, 1)
+}
+
+// RecordOutlier counts a reading from provider that disagreed with the
+// other providers and was left out of the aggregated result.
+func (s *StatsTracker) RecordOutlier(provider string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.provider(provider).Outliers++
+}
+
+// SetBreakerState records the current circuit breaker state of provider.
+func (s *StatsTracker) SetBreakerState(provider, state string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.provider(provider).BreakerState = state
+}
+
+// RecordBudgetExhausted counts a call to provider that was skipped because
+// its quota budget was used up.
+func (s *StatsTracker) RecordBudgetExhausted(provider string) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.provider(provider).BudgetExhausted++
+}
+
+// provider returns the stats entry for name, creating it if needed.
+// Callers must hold s.mu.
+func (s *StatsTracker) provider(name string) *models.ProviderStats {
+    stats, ok := s.providers[name]
+    if !ok {
+        stats = &models.ProviderStats{}
+        s.providers[name] = stats
+    }
+    return stats
+}
+
 func (s *StatsTracker) GetStats() models.StatsResponse {
+    s.mu.Lock()
+    providers := make(map[string]models.ProviderStats, len(s.providers))
+    for name, stats := range s.providers {
+        providers[name] = *stats
+    }
+    s.mu.Unlock()
+
     return models.StatsResponse{
         TotalRequests:  atomic.LoadInt64(&s.totalRequests),
         CacheHits:      atomic.LoadInt64(&s.cacheHits),
         CacheMisses:    atomic.LoadInt64(&s.cacheMisses),
         ApiCalls:       atomic.LoadInt64(&s.apiCalls),
         RateLimitHits:  atomic.LoadInt64(&s.rateLimitHits),
+        RateLimitQueued: atomic.LoadInt64(&s.rateLimitQueued),
+        CoalescedRequests: atomic.LoadInt64(&s.coalesced),
+        RemoteCoalescedRequests: atomic.LoadInt64(&s.remoteCoalesced),
+        StaleHits:      atomic.LoadInt64(&s.staleHits),
+        StaleIfErrorHits: atomic.LoadInt64(&s.staleIfError),
+        NegativeCacheHits: atomic.LoadInt64(&s.negativeHits),
+        CacheErrors:    atomic.LoadInt64(&s.cacheErrors),
+        Providers:      providers,
     }
 }
\ No newline at end of file
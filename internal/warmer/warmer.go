@@ -0,0 +1,224 @@
+package warmer
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/internal/location"
+)
+
+const (
+	defaultInterval     = time.Minute
+	defaultRefreshAhead = 2 * time.Minute
+	defaultHalfLife     = 24 * time.Hour
+	defaultConcurrency  = 4
+
+	// maxTracked bounds the number of locations whose popularity is
+	// tracked, so requests for random cities cannot grow it without limit.
+	maxTracked = 10000
+	// minScore is the score below which a location is forgotten.
+	minScore = 0.05
+)
+
+// Refresher refreshes the cache entry for a city when it is missing or goes
+// stale within refreshAhead, reporting whether it called the providers.
+type Refresher interface {
+	Warm(ctx context.Context, city string, refreshAhead time.Duration) (bool, error)
+}
+
+// Options configures the warmer. Pinned cities are always kept warm; of
+// the other requested locations, the TopN most popular ones with a score of
+// at least MinRequests are.
+type Options struct {
+	Pinned      []string
+	TopN        int
+	MinRequests float64
+
+	// Interval is how often entries are checked. It should be shorter than
+	// RefreshAhead, or entries may expire between two checks.
+	Interval     time.Duration
+	RefreshAhead time.Duration
+	// HalfLife is how long it takes a location's request count to decay to
+	// half, so yesterday's traffic still counts this morning.
+	HalfLife    time.Duration
+	Concurrency int
+}
+
+// Warmer keeps the cache entries of pinned and popular locations fresh so
+// requests for them don't wait on the providers.
+type Warmer struct {
+	opts Options
+
+	mu        sync.Mutex
+	locations map[string]*popularity
+	decayedAt time.Time
+}
+
+type popularity struct {
+	city  string
+	score float64
+}
+
+func NewWarmer(opts Options) *Warmer {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultInterval
+	}
+	if opts.RefreshAhead <= 0 {
+		opts.RefreshAhead = defaultRefreshAhead
+	}
+	if opts.HalfLife <= 0 {
+		opts.HalfLife = defaultHalfLife
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+
+	return &Warmer{
+		opts:      opts,
+		locations: make(map[string]*popularity),
+		decayedAt: time.Now(),
+	}
+}
+
+// Record counts a request for city, whose cache key is key. Spellings that
+// share a key share a count; the latest spelling is used to refresh it. Once
+// a query resolves to a location key, the count collected under its query
+// key moves over to it.
+func (w *Warmer) Record(key, city string) {
+	if w.opts.TopN <= 0 {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	entry, ok := w.locations[key]
+	if queryKey := location.QueryKey(city); queryKey != key {
+		if byQuery, tracked := w.locations[queryKey]; tracked {
+			delete(w.locations, queryKey)
+			if ok {
+				entry.score += byQuery.score
+			} else {
+				entry, ok = byQuery, true
+				w.locations[key] = entry
+			}
+		}
+	}
+	if !ok {
+		if len(w.locations) >= maxTracked && !w.evictUnpopular() {
+			return
+		}
+		entry = &popularity{}
+		w.locations[key] = entry
+	}
+	entry.city = city
+	entry.score++
+}
+
+// evictUnpopular drops one location below the popularity threshold to make
+// room for another, reporting whether it found one. Callers must hold w.mu.
+func (w *Warmer) evictUnpopular() bool {
+	for key, location := range w.locations {
+		if location.score < w.opts.MinRequests {
+			delete(w.locations, key)
+			return true
+		}
+	}
+	return false
+}
+
+// Popular returns the cities to keep warm because of their traffic, most
+// popular first.
+func (w *Warmer) Popular() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	candidates := make([]*popularity, 0, len(w.locations))
+	for _, location := range w.locations {
+		if location.score >= w.opts.MinRequests {
+			candidates = append(candidates, location)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if len(candidates) > w.opts.TopN {
+		candidates = candidates[:w.opts.TopN]
+	}
+
+	cities := make([]string, len(candidates))
+	for i, location := range candidates {
+		cities[i] = location.city
+	}
+	return cities
+}
+
+// Run warms the cache right away and then every interval until ctx is done.
+func (w *Warmer) Run(ctx context.Context, refresher Refresher) {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.decay()
+		w.warm(ctx, refresher)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// warm refreshes every pinned and popular city that needs it, a few at a
+// time.
+func (w *Warmer) warm(ctx context.Context, refresher Refresher) {
+	cities := append(append([]string{}, w.opts.Pinned...), w.Popular()...)
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, w.opts.Concurrency)
+	for _, city := range cities {
+		select {
+		case <-ctx.Done():
+			return
+		case slots <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(city string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			refreshed, err := refresher.Warm(ctx, city, w.opts.RefreshAhead)
+			switch {
+			case err != nil:
+				log.Printf("[ERROR] Failed to warm cache for city %s: %v", city, err)
+			case refreshed:
+				log.Printf("[DEBUG] Warmed cache for city: %s", city)
+			}
+		}(city)
+	}
+	wg.Wait()
+}
+
+// decay scales every score down by the time passed since the last decay,
+// forgetting locations that are no longer requested.
+func (w *Warmer) decay() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	factor := math.Pow(0.5, float64(now.Sub(w.decayedAt))/float64(w.opts.HalfLife))
+	w.decayedAt = now
+
+	for key, location := range w.locations {
+		location.score *= factor
+		if location.score < minScore {
+			delete(w.locations, key)
+		}
+	}
+}
@@ -14,10 +14,13 @@ import (
 	"github.com/devonphone/weather-aggregator/api/handlers"
 	"github.com/devonphone/weather-aggregator/api/routes"
 	"github.com/devonphone/weather-aggregator/config"
+	"github.com/devonphone/weather-aggregator/internal/aggregate"
 	"github.com/devonphone/weather-aggregator/internal/cache"
 	"github.com/devonphone/weather-aggregator/internal/providers"
+	"github.com/devonphone/weather-aggregator/internal/quota"
 	"github.com/devonphone/weather-aggregator/internal/rate_limit"
 	"github.com/devonphone/weather-aggregator/internal/stats"
+	"github.com/devonphone/weather-aggregator/internal/warmer"
 	"github.com/gorilla/mux"
 )
 
@@ -28,27 +31,194 @@ func main() {
         log.Fatalf("Failed to load config: %v", err)
     }
 
-    // Initialize cache
-    weatherCache, err := cache.NewRedisCache(cfg.CacheDuration)
-    if err != nil {
-        log.Fatalf("Failed to initialize cache: %v", err)
+    // Initialize cache. Entries are kept past CACHE_DURATION so they can
+    // still be served stale while revalidating or when providers fail.
+    cacheRetention := cfg.CacheDuration + max(cfg.CacheStaleWhileRevalidate, cfg.CacheStaleIfError)
+    redisConfig := cache.RedisConfig{
+        URL:              cfg.RedisUrl,
+        Addr:             cfg.RedisAddr,
+        Username:         cfg.RedisUsername,
+        Password:         cfg.RedisPassword,
+        Mode:             cfg.RedisMode,
+        Addrs:            cfg.RedisAddrs,
+        SentinelMaster:   cfg.RedisSentinelMaster,
+        SentinelPassword: cfg.RedisSentinelPassword,
     }
-    defer weatherCache.Close()
+    var weatherCache cache.Cache
+    switch cfg.CacheBackend {
+    case "memory":
+        log.Printf("Using in-memory cache with up to %d entries", cfg.CacheMaxEntries)
+        weatherCache = cache.NewMemoryCache(cfg.CacheMaxEntries, cacheRetention)
+    case "redis":
+        redisCache, err := cache.NewRedisCache(redisConfig, cacheRetention)
+        if err != nil {
+            log.Fatalf("Failed to initialize cache: %v", err)
+        }
+        defer redisCache.Close()
+        weatherCache = redisCache
+    case "tiered":
+        redisCache, err := cache.NewRedisCache(redisConfig, cacheRetention)
+        if err != nil {
+            log.Fatalf("Failed to initialize cache: %v", err)
+        }
+        defer redisCache.Close()
+        tieredCache, err := cache.NewTieredCache(redisCache, cfg.CacheMaxEntries, cfg.CacheL1TTL)
+        if err != nil {
+            log.Fatalf("Failed to initialize tiered cache: %v", err)
+        }
+        defer tieredCache.Close()
+        weatherCache = tieredCache
+    default:
+        log.Fatalf("Invalid CACHE_BACKEND %q, expected redis, memory or tiered", cfg.CacheBackend)
+    }
+
+    // Initialize stats tracker
+    statsTracker := stats.NewStatsTracker()
 
     // Initialize weather providers
     weatherProviders := []providers.WeatherProvider{
 		providers.NewWeatherAPIProvider(cfg.WeatherApiKey),
         providers.NewOpenWeatherProvider(cfg.OpenWeatherApiKey),
+        providers.NewOpenMeteoProvider(),
+    }
+
+    // api.weather.gov asks callers to identify themselves, so the NWS
+    // provider is only enabled once a contact User-Agent is configured
+    if cfg.NWSUserAgent != "" {
+        weatherProviders = append(weatherProviders, providers.NewNWSProvider(cfg.NWSUserAgent))
+    }
+    weatherProviders = providers.SortByPriority(weatherProviders, cfg.ProviderPriority)
+
+    // Count calls to providers with a limited plan, so they are skipped
+    // before their quota runs out
+    quotaLimits, err := quota.ParseLimits(cfg.ProviderQuotas)
+    if err != nil {
+        log.Fatalf("Invalid PROVIDER_QUOTAS: %v", err)
+    }
+    if len(quotaLimits) > 0 {
+        quotaClient, err := cache.NewRedisClient(redisConfig)
+        if err != nil {
+            log.Fatalf("Failed to initialize quota budget: %v", err)
+        }
+        defer quotaClient.Close()
+        budget := quota.NewRedisBudget(quotaClient, quotaLimits, cfg.QuotaReserve)
+        for i, provider := range weatherProviders {
+            weatherProviders[i] = providers.NewBudgetedProvider(provider, budget)
+        }
+    }
+
+    // Wrap each provider in a retry for transient failures, then in a
+    // circuit breaker so a provider that is down or out of quota stops
+    // being called for a while
+    retryConfig := providers.RetryConfig{
+        MaxAttempts: cfg.RetryMaxAttempts,
+        BaseDelay:   cfg.RetryBaseDelay,
+        MaxDelay:    cfg.RetryMaxDelay,
+    }
+    breakerConfig := providers.CircuitBreakerConfig{
+        FailureThreshold:   cfg.BreakerFailureThreshold,
+        ErrorRateThreshold: cfg.BreakerErrorRate,
+        MinRequests:        cfg.BreakerMinRequests,
+        Window:             cfg.BreakerWindow,
+        CoolOff:            cfg.BreakerCoolOff,
+        OnStateChange: func(provider string, from, to providers.BreakerState) {
+            log.Printf("[WARN] Circuit breaker for %s moved from %s to %s", provider, from, to)
+            statsTracker.SetBreakerState(provider, string(to))
+        },
+    }
+    breakers := make([]*providers.CircuitBreaker, 0, len(weatherProviders))
+    for i, provider := range weatherProviders {
+        breaker := providers.NewCircuitBreaker(
+            providers.NewRetryingProvider(provider, retryConfig),
+            breakerConfig,
+        )
+        statsTracker.SetBreakerState(provider.GetProviderName(), string(providers.BreakerClosed))
+        breakers = append(breakers, breaker)
+        weatherProviders[i] = breaker
+    }
+
+    // Initialize rate limiter. The memory backend limits each replica on its
+    // own; the redis backend enforces the limit across all of them. In client
+    // scope the global limiter caps all clients together.
+    globalRequests := cfg.RateLimitRequests
+    if cfg.RateLimitScope == "client" {
+        globalRequests = cfg.RateLimitGlobalRequests
+    }
+    var rateLimiter ratelimit.RateLimiter
+    var clientLimiter ratelimit.Keyed
+    switch cfg.RateLimitBackend {
+    case "memory":
+        rateLimiter = ratelimit.NewTokenBucketLimiter(globalRequests, cfg.RateLimitDuration)
+        clientLimiter = ratelimit.NewKeyedLimiter(cfg.RateLimitRequests, cfg.RateLimitDuration, cfg.RateLimitIdleTTL, cfg.RateLimitMaxClients)
+    case "redis":
+        redisClient, err := cache.NewRedisClient(redisConfig)
+        if err != nil {
+            log.Fatalf("Failed to initialize rate limiter: %v", err)
+        }
+        defer redisClient.Close()
+        rateLimiter = ratelimit.NewRedisLimiter(redisClient, "global", globalRequests, cfg.RateLimitDuration)
+        clientLimiter = ratelimit.NewRedisLimiter(redisClient, "client", cfg.RateLimitRequests, cfg.RateLimitDuration)
+    default:
+        log.Fatalf("Invalid RATE_LIMIT_BACKEND %q, expected memory or redis", cfg.RateLimitBackend)
+    }
+
+    // In client scope every known API key or IP address gets its own bucket
+    var clientIdentifier *ratelimit.ClientIdentifier
+    switch cfg.RateLimitScope {
+    case "client":
+        clientIdentifier, err = ratelimit.NewClientIdentifier(cfg.RateLimitAPIKeyHeader, cfg.RateLimitAPIKeys, cfg.RateLimitTrustedProxies)
+        if err != nil {
+            log.Fatalf("Invalid rate limit config: %v", err)
+        }
+    case "global":
+        clientLimiter = nil
+    default:
+        log.Fatalf("Invalid RATE_LIMIT_SCOPE %q, expected client or global", cfg.RateLimitScope)
     }
 
-    // Initialize rate limiter
-    rateLimiter := ratelimit.NewTokenBucketLimiter(
-        cfg.RateLimitRequests,
-        cfg.RateLimitDuration,
+    // In queue mode cache misses over the limit wait for it briefly
+    // instead of being rejected right away
+    var rateLimitQueueWait time.Duration
+    switch cfg.RateLimitMode {
+    case "reject":
+    case "queue":
+        rateLimitQueueWait = cfg.RateLimitQueueWait
+    default:
+        log.Fatalf("Invalid RATE_LIMIT_MODE %q, expected reject or queue", cfg.RateLimitMode)
+    }
+
+    // Initialize provider result aggregation
+    strategy, err := aggregate.ParseStrategy(cfg.AggregationStrategy)
+    if err != nil {
+        log.Fatalf("Invalid aggregation config: %v", err)
+    }
+    aggregator := aggregate.NewAggregator(
+        strategy,
+        cfg.ProviderWeights,
+        cfg.AggregationQuorum,
+        aggregate.Thresholds{
+            Temperature: cfg.OutlierTemperatureThreshold,
+            Humidity:    cfg.OutlierHumidityThreshold,
+        },
     )
 
-    // Initialize stats tracker
-    statsTracker := stats.NewStatsTracker()
+    fetchMode := handlers.FetchMode(cfg.FetchMode)
+    if fetchMode != handlers.FetchFanOut && fetchMode != handlers.FetchHedged {
+        log.Fatalf("Invalid FETCH_MODE %q, expected fanout or hedged", cfg.FetchMode)
+    }
+
+    // Initialize cache warming for pinned and popular cities
+    var cacheWarmer *warmer.Warmer
+    if len(cfg.WarmPinnedCities) > 0 || cfg.WarmTopN > 0 {
+        cacheWarmer = warmer.NewWarmer(warmer.Options{
+            Pinned:       cfg.WarmPinnedCities,
+            TopN:         cfg.WarmTopN,
+            MinRequests:  cfg.WarmMinRequests,
+            Interval:     cfg.WarmInterval,
+            RefreshAhead: cfg.WarmRefreshAhead,
+            HalfLife:     cfg.WarmHalfLife,
+        })
+    }
 
     // Initialize handlers
     weatherHandler := handlers.NewWeatherHandler(
@@ -56,12 +226,32 @@ func main() {
         weatherCache,
         rateLimiter,
         statsTracker,
+        handlers.WeatherHandlerOptions{
+            Aggregator:      aggregator,
+            ProviderTimeout: cfg.ProviderTimeout,
+            FetchMode:       fetchMode,
+            HedgeDelay:      cfg.HedgeDelay,
+            HedgePercentile: cfg.HedgePercentile,
+            FetchLockTTL:    cfg.FetchLockTTL,
+            FetchLockWait:   cfg.FetchLockWait,
+            FreshFor:        cfg.CacheDuration,
+            StaleWhileRevalidate: cfg.CacheStaleWhileRevalidate,
+            StaleIfError:    cfg.CacheStaleIfError,
+            NotFoundTTL:     cfg.CacheNotFoundTTL,
+            Warmer:          cacheWarmer,
+            ClientLimiter:   clientLimiter,
+            ClientIdentifier: clientIdentifier,
+            RateLimitQueueWait:   rateLimitQueueWait,
+            RateLimitQueueLength: cfg.RateLimitQueueLength,
+        },
     )
     statsHandler := handlers.NewStatsHandler(statsTracker)
+    healthHandler := handlers.NewHealthHandler(weatherCache)
+    adminHandler := handlers.NewAdminHandler(breakers, weatherCache)
 
     // Initialize router and API
     router := mux.NewRouter()
-    api := routes.NewAPI(weatherHandler, statsHandler)
+    api := routes.NewAPI(weatherHandler, statsHandler, healthHandler, adminHandler, cfg.AdminToken)
     api.SetupRoutes(router)
 
     // Create server
@@ -73,6 +263,13 @@ func main() {
         IdleTimeout:  60 * time.Second,
     }
 
+    // Start cache warming
+    warmerCtx, stopWarmer := context.WithCancel(context.Background())
+    defer stopWarmer()
+    if cacheWarmer != nil {
+        go cacheWarmer.Run(warmerCtx, weatherHandler)
+    }
+
     // Channel to listen for errors coming from the server
     serverErrors := make(chan error, 1)
 
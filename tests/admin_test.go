@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devonphone/weather-aggregator/api/handlers"
+	"github.com/devonphone/weather-aggregator/api/routes"
+	"github.com/devonphone/weather-aggregator/internal/cache"
+	"github.com/devonphone/weather-aggregator/internal/location"
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"github.com/devonphone/weather-aggregator/internal/stats"
+	"github.com/gorilla/mux"
+)
+
+const testAdminToken = "secret"
+
+func newAdminRouter(weatherCache cache.Cache) *mux.Router {
+	tracker := stats.NewStatsTracker()
+	router := mux.NewRouter()
+	routes.NewAPI(
+		newTestHandler(nil, handlers.WeatherHandlerOptions{}),
+		handlers.NewStatsHandler(tracker),
+		handlers.NewHealthHandler(weatherCache),
+		handlers.NewAdminHandler(nil, weatherCache),
+		testAdminToken,
+	).SetupRoutes(router)
+	return router
+}
+
+func adminRequest(router *mux.Router, method, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(method, target, nil)
+	request.Header.Set("Authorization", "Bearer "+testAdminToken)
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestAdminCacheRequiresToken(t *testing.T) {
+	router := newAdminRouter(cache.NewMemoryCache(10, time.Minute))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/admin/cache", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %d", recorder.Code)
+	}
+}
+
+func TestAdminCacheListsAndShowsEntries(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	redisCache := newTestRedisCache(t, server)
+	router := newAdminRouter(redisCache)
+
+	jakarta, bandung := location.QueryKey("Jakarta"), location.QueryKey("Bandung")
+	redisCache.Set(ctx, jakarta, &models.WeatherData{City: "Jakarta", Temperature: 30})
+	redisCache.SetNotFound(ctx, bandung, 30*time.Second)
+	server.Set("other-app:session", "x")
+
+	recorder := adminRequest(router, "GET", "/admin/cache/keys")
+	var keys models.CacheKeysResponse
+	json.NewDecoder(recorder.Body).Decode(&keys)
+	if !reflect.DeepEqual(keys.Keys, []string{bandung, jakarta}) || keys.Truncated {
+		t.Errorf("Expected only the aggregator's keys, got %+v", keys)
+	}
+
+	recorder = adminRequest(router, "GET", "/admin/cache/keys?limit=1")
+	json.NewDecoder(recorder.Body).Decode(&keys)
+	if len(keys.Keys) != 1 || !keys.Truncated {
+		t.Errorf("Expected a truncated list of one key, got %+v", keys)
+	}
+
+	recorder = adminRequest(router, "GET", "/admin/cache/entries?key="+jakarta)
+	var entry models.CacheEntryResponse
+	json.NewDecoder(recorder.Body).Decode(&entry)
+	if entry.Data == nil || entry.Data.Temperature != 30 || entry.TTLSeconds != 60 {
+		t.Errorf("Expected the entry with its TTL, got %+v", entry)
+	}
+
+	recorder = adminRequest(router, "GET", "/admin/cache/entries?key="+bandung)
+	entry = models.CacheEntryResponse{}
+	json.NewDecoder(recorder.Body).Decode(&entry)
+	if !entry.NotFound || entry.TTLSeconds != 30 {
+		t.Errorf("Expected the not found entry with its TTL, got %+v", entry)
+	}
+
+	unlock, _, _ := redisCache.TryLock(ctx, jakarta, 10*time.Second)
+	defer unlock()
+	recorder = adminRequest(router, "GET", "/admin/cache/entries?key="+jakarta+cache.LockSuffix)
+	entry = models.CacheEntryResponse{}
+	json.NewDecoder(recorder.Body).Decode(&entry)
+	if recorder.Code != http.StatusOK || !entry.Lock || entry.TTLSeconds != 10 {
+		t.Errorf("Expected the fetch lock with its TTL, got %d: %+v", recorder.Code, entry)
+	}
+
+	if recorder := adminRequest(router, "GET", "/admin/cache/entries?key=other-app:session"); recorder.Code != http.StatusBadRequest {
+		t.Errorf("Expected keys outside the namespace to be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestAdminCachePurgesAndFlushes(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	redisCache := newTestRedisCache(t, server)
+	router := newAdminRouter(redisCache)
+
+	for _, city := range []string{"Jakarta", "Bandung", "Surabaya"} {
+		redisCache.Set(ctx, location.QueryKey(city), &models.WeatherData{City: city})
+	}
+	server.Set("other-app:session", "x")
+
+	recorder := adminRequest(router, "DELETE", "/admin/cache/entries?key="+location.QueryKey("Jakarta"))
+	var purge models.CachePurgeResponse
+	json.NewDecoder(recorder.Body).Decode(&purge)
+	if recorder.Code != http.StatusOK || purge.Deleted != 1 || server.Exists(location.QueryKey("Jakarta")) {
+		t.Fatalf("Expected the key to be purged, got %d: %+v", recorder.Code, purge)
+	}
+
+	recorder = adminRequest(router, "DELETE", "/admin/cache/entries?key="+location.QueryKey("Jakarta"))
+	json.NewDecoder(recorder.Body).Decode(&purge)
+	if purge.Deleted != 0 {
+		t.Errorf("Expected purging a missing key to delete nothing, got %+v", purge)
+	}
+
+	recorder = adminRequest(router, "DELETE", "/admin/cache/entries?prefix="+location.QueryKey("B"))
+	json.NewDecoder(recorder.Body).Decode(&purge)
+	if purge.Deleted != 1 || server.Exists(location.QueryKey("Bandung")) {
+		t.Errorf("Expected the prefix to be purged, got %+v", purge)
+	}
+
+	recorder = adminRequest(router, "DELETE", "/admin/cache")
+	json.NewDecoder(recorder.Body).Decode(&purge)
+	if purge.Deleted != 1 || server.Exists(location.QueryKey("Surabaya")) {
+		t.Errorf("Expected the namespace to be flushed, got %+v", purge)
+	}
+	if !server.Exists("other-app:session") {
+		t.Error("Expected keys outside the namespace to survive a flush")
+	}
+}
+
+func TestMemoryCacheListsAndPurgesKeys(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := cache.NewMemoryCache(10, time.Minute)
+	for _, key := range []string{"weather:a", "weather:b", "other:c"} {
+		memoryCache.Set(ctx, key, &models.WeatherData{})
+	}
+	// Expired but not yet swept entries must not show up or count as purged
+	memoryCache.SetNotFound(ctx, "weather:expired", time.Millisecond)
+	memoryCache.SetNotFound(ctx, "other:expired", time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if keys, _ := memoryCache.Keys(ctx, "weather:*", 10); !reflect.DeepEqual(keys, []string{"weather:a", "weather:b"}) {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+	if ttl, _ := memoryCache.TTL(ctx, "weather:a"); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("Unexpected TTL %v", ttl)
+	}
+	if deleted, _ := memoryCache.Delete(ctx, "other:expired"); deleted != 0 {
+		t.Errorf("Expected purging an expired key to delete nothing, got %d", deleted)
+	}
+	if deleted, _ := memoryCache.DeletePrefix(ctx, "weather:"); deleted != 2 || memoryCache.Len() != 1 {
+		t.Errorf("Expected 2 keys purged and 1 left, got %d and %d", deleted, memoryCache.Len())
+	}
+}
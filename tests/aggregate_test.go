@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/devonphone/weather-aggregator/internal/aggregate"
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+func sampleResults() []*models.WeatherData {
+	return []*models.WeatherData{
+		{City: "Jakarta", Temperature: 30, Humidity: 70, Condition: "Partly cloudy", Source: "WeatherAPIMap"},
+		{City: "Jakarta", Temperature: 32, Humidity: 60, Condition: "scattered clouds", Source: "OpenWeatherMap"},
+		{City: "Jakarta", Temperature: 31, Humidity: 66, Condition: "Slight rain showers", Source: "OpenMeteo"},
+	}
+}
+
+func TestAggregatorMedian(t *testing.T) {
+	aggregator := aggregate.NewAggregator(aggregate.Median, nil, 0, aggregate.Thresholds{})
+
+	fused, err := aggregator.Fuse(sampleResults())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if fused.Temperature != 31 || fused.Humidity != 66 {
+		t.Errorf("Expected median 31/66, got %f/%d", fused.Temperature, fused.Humidity)
+	}
+	if fused.Source != aggregate.AggregatedSource {
+		t.Errorf("Expected source %s, got %s", aggregate.AggregatedSource, fused.Source)
+	}
+	if len(fused.Sources) != 3 {
+		t.Errorf("Expected 3 contributing sources, got %v", fused.Sources)
+	}
+	if fused.Condition != "Partly cloudy" {
+		t.Errorf("Expected condition of the first result, got %q", fused.Condition)
+	}
+}
+
+func TestAggregatorWeightedMean(t *testing.T) {
+	aggregator := aggregate.NewAggregator(aggregate.WeightedMean, map[string]float64{
+		"WeatherAPIMap":  2,
+		"OpenWeatherMap": 1,
+		"OpenMeteo":      1,
+	}, 0, aggregate.Thresholds{})
+
+	fused, err := aggregator.Fuse(sampleResults())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// (2*30 + 32 + 31) / 4 and (2*70 + 60 + 66) / 4
+	if fused.Temperature != 30.75 || fused.Humidity != 67 {
+		t.Errorf("Expected weighted mean 30.75/67, got %f/%d", fused.Temperature, fused.Humidity)
+	}
+}
+
+func TestAggregatorQuorum(t *testing.T) {
+	aggregator := aggregate.NewAggregator(aggregate.Quorum, nil, 2, aggregate.Thresholds{})
+
+	if aggregator.Satisfied(1, 3) {
+		t.Error("Expected quorum of 2 not to be satisfied by 1 result")
+	}
+	if !aggregator.Satisfied(2, 3) {
+		t.Error("Expected quorum of 2 to be satisfied by 2 results")
+	}
+
+	if _, err := aggregator.Fuse(sampleResults()[:1]); !errors.Is(err, aggregate.ErrQuorumNotMet) {
+		t.Errorf("Expected ErrQuorumNotMet, got %v", err)
+	}
+
+	fused, err := aggregator.Fuse(sampleResults()[:2])
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if fused.Temperature != 31 {
+		t.Errorf("Expected median of quorum 31, got %f", fused.Temperature)
+	}
+}
+
+func TestAggregatorFirstWins(t *testing.T) {
+	aggregator := aggregate.NewAggregator(aggregate.FirstWins, nil, 0, aggregate.Thresholds{})
+
+	fused, err := aggregator.Fuse(sampleResults())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if fused.Source != "WeatherAPIMap" || fused.Temperature != 30 {
+		t.Errorf("Expected the first result unchanged, got %+v", fused)
+	}
+}
+
+func TestAggregatorExcludesOutliers(t *testing.T) {
+	aggregator := aggregate.NewAggregator(aggregate.Median, nil, 0, aggregate.Thresholds{Temperature: 3, Humidity: 15})
+
+	results := sampleResults()
+	results[1].Temperature = 39
+
+	fused, err := aggregator.Fuse(results)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(fused.Outliers) != 1 || fused.Outliers[0] != "OpenWeatherMap" {
+		t.Errorf("Expected OpenWeatherMap to be flagged, got %v", fused.Outliers)
+	}
+	if len(fused.Sources) != 2 {
+		t.Errorf("Expected outlier to be left out of sources, got %v", fused.Sources)
+	}
+	if fused.Temperature != 30.5 {
+		t.Errorf("Expected median of remaining readings 30.5, got %f", fused.Temperature)
+	}
+	if fused.Confidence == nil || *fused.Confidence < 0.66 || *fused.Confidence > 0.67 {
+		t.Errorf("Expected confidence of 2/3, got %v", fused.Confidence)
+	}
+}
+
+func TestAnalyzeTwoDisagreeingProviders(t *testing.T) {
+	results := sampleResults()[:2]
+	results[1].Temperature = 40
+
+	analysis := aggregate.Analyze(results, aggregate.Thresholds{Temperature: 3})
+
+	if len(analysis.Outliers) != 0 || len(analysis.Agreeing) != 2 {
+		t.Errorf("Expected no exclusions with two providers, got %d outliers", len(analysis.Outliers))
+	}
+	if analysis.Confidence != 0.5 {
+		t.Errorf("Expected confidence 0.5, got %f", analysis.Confidence)
+	}
+}
@@ -0,0 +1,321 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devonphone/weather-aggregator/api/handlers"
+	"github.com/devonphone/weather-aggregator/internal/cache"
+	"github.com/devonphone/weather-aggregator/internal/location"
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"github.com/devonphone/weather-aggregator/internal/providers"
+	ratelimit "github.com/devonphone/weather-aggregator/internal/rate_limit"
+	"github.com/devonphone/weather-aggregator/internal/stats"
+)
+
+func newTestRedisCache(t *testing.T, server *miniredis.Miniredis) *cache.RedisCache {
+	t.Helper()
+	redisCache, err := cache.NewRedisCache(cache.RedisConfig{Addr: server.Addr()}, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to connect to miniredis: %v", err)
+	}
+	t.Cleanup(func() { redisCache.Close() })
+	return redisCache
+}
+
+func TestFetchLockSharesFetchAcrossReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	var replicas []*handlers.WeatherHandler
+	var upstreams []*stubProvider
+	var trackers []*stats.StatsTracker
+	for i := 0; i < 2; i++ {
+		provider := &stubProvider{name: "Slow", delay: 150 * time.Millisecond, data: &models.WeatherData{Temperature: 30}}
+		tracker := stats.NewStatsTracker()
+		replicas = append(replicas, handlers.NewWeatherHandler(
+			[]providers.WeatherProvider{provider},
+			newTestRedisCache(t, server),
+			ratelimit.NewTokenBucketLimiter(100, time.Minute),
+			tracker,
+			handlers.WeatherHandlerOptions{
+				FetchLockTTL:  time.Second,
+				FetchLockWait: time.Second,
+			},
+		))
+		upstreams = append(upstreams, provider)
+		trackers = append(trackers, tracker)
+	}
+
+	var wg sync.WaitGroup
+	for _, replica := range replicas {
+		wg.Add(1)
+		go func(handler *handlers.WeatherHandler) {
+			defer wg.Done()
+			if recorder := getWeather(handler, "Jakarta"); recorder.Code != http.StatusOK {
+				t.Errorf("Expected 200, got %d: %s", recorder.Code, recorder.Body)
+			}
+		}(replica)
+	}
+	wg.Wait()
+
+	calls := upstreams[0].Calls() + upstreams[1].Calls()
+	if calls != 1 {
+		t.Errorf("Expected exactly one replica to call the provider, got %d calls", calls)
+	}
+
+	waited := trackers[0].GetStats().RemoteCoalescedRequests + trackers[1].GetStats().RemoteCoalescedRequests
+	if waited != 1 {
+		t.Errorf("Expected one replica to wait for the other, got %d", waited)
+	}
+
+	if server.Exists(location.QueryKey("Jakarta") + ":lock") {
+		t.Error("Expected the fetch lock to be released")
+	}
+}
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := cache.NewMemoryCache(2, time.Minute)
+
+	memoryCache.Set(ctx, "jakarta", &models.WeatherData{City: "Jakarta"})
+	memoryCache.Set(ctx, "london", &models.WeatherData{City: "London"})
+
+	// Touch jakarta so london becomes the least recently used entry
+	memoryCache.Get(ctx, "jakarta")
+	memoryCache.Set(ctx, "tokyo", &models.WeatherData{City: "Tokyo"})
+
+	if data, _ := memoryCache.Get(ctx, "london"); data != nil {
+		t.Error("Expected london to be evicted")
+	}
+	for _, key := range []string{"jakarta", "tokyo"} {
+		data, err := memoryCache.Get(ctx, key)
+		if err != nil || data == nil {
+			t.Fatalf("Expected %s to be cached, got %v, %v", key, data, err)
+		}
+		if !data.Cached || data.CachedAt == nil {
+			t.Errorf("Expected %s to be marked cached with a timestamp, got %+v", key, data)
+		}
+	}
+	if memoryCache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", memoryCache.Len())
+	}
+}
+
+func TestMemoryCacheExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := cache.NewMemoryCache(10, 20*time.Millisecond)
+
+	memoryCache.Set(ctx, "jakarta", &models.WeatherData{City: "Jakarta"})
+	time.Sleep(30 * time.Millisecond)
+
+	if data, _ := memoryCache.Get(ctx, "jakarta"); data != nil {
+		t.Error("Expected entry to expire after the TTL")
+	}
+	if memoryCache.Len() != 0 {
+		t.Errorf("Expected expired entry to be dropped, got %d entries", memoryCache.Len())
+	}
+}
+
+func TestMemoryCacheExpiresNotFoundEntries(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := cache.NewMemoryCache(10, time.Hour)
+
+	memoryCache.SetNotFound(ctx, "jakartaa", 20*time.Millisecond)
+	if _, err := memoryCache.Get(ctx, "jakartaa"); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if data, err := memoryCache.Get(ctx, "jakartaa"); data != nil || err != nil {
+		t.Errorf("Expected the not found entry to expire after its own TTL, got %+v, %v", data, err)
+	}
+}
+
+func TestRedisCacheStoresNotFoundEntries(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	redisCache := newTestRedisCache(t, server)
+
+	redisCache.SetNotFound(ctx, "jakartaa", time.Minute)
+	if _, err := redisCache.Get(ctx, "jakartaa"); !errors.Is(err, cache.ErrNotFound) {
+		t.Fatalf("Expected ErrNotFound, got %v", err)
+	}
+	if ttl := server.TTL("jakartaa"); ttl != time.Minute {
+		t.Errorf("Expected the not found TTL, got %v", ttl)
+	}
+
+	server.FastForward(time.Minute)
+	if data, err := redisCache.Get(ctx, "jakartaa"); data != nil || err != nil {
+		t.Errorf("Expected the not found entry to expire, got %+v, %v", data, err)
+	}
+}
+
+func TestMemoryCacheReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	memoryCache := cache.NewMemoryCache(10, time.Minute)
+
+	original := &models.WeatherData{City: "Jakarta", Temperature: 30}
+	memoryCache.Set(ctx, "jakarta", original)
+	original.Temperature = 99
+
+	data, _ := memoryCache.Get(ctx, "jakarta")
+	data.Temperature = 50
+
+	if again, _ := memoryCache.Get(ctx, "jakarta"); again.Temperature != 30 {
+		t.Errorf("Expected cached value to be unaffected by callers, got %f", again.Temperature)
+	}
+}
+
+func TestTieredCachePropagatesInvalidations(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	newTiered := func() *cache.TieredCache {
+		tiered, err := cache.NewTieredCache(newTestRedisCache(t, server), 10, time.Minute)
+		if err != nil {
+			t.Fatalf("Failed to create tiered cache: %v", err)
+		}
+		t.Cleanup(func() { tiered.Close() })
+		return tiered
+	}
+	replicaA, replicaB := newTiered(), newTiered()
+
+	replicaA.Set(ctx, "jakarta", &models.WeatherData{City: "Jakarta", Temperature: 30})
+
+	// Populates replica B's L1 from Redis
+	if data, _ := replicaB.Get(ctx, "jakarta"); data == nil || data.Temperature != 30 {
+		t.Fatalf("Expected replica B to read through to Redis, got %+v", data)
+	}
+
+	// With Redis gone, only L1 can answer
+	server.FlushAll()
+	if data, _ := replicaB.Get(ctx, "jakarta"); data == nil {
+		t.Fatal("Expected replica B to serve from L1")
+	}
+
+	replicaA.Set(ctx, "jakarta", &models.WeatherData{City: "Jakarta", Temperature: 32})
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if data, _ := replicaB.Get(ctx, "jakarta"); data != nil && data.Temperature == 32 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected replica B's L1 entry to be invalidated by replica A's write")
+}
+
+func TestTieredCachePropagatesPrefixPurges(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	replicas := make([]*cache.TieredCache, 2)
+	for i := range replicas {
+		tiered, err := cache.NewTieredCache(newTestRedisCache(t, server), 10, time.Minute)
+		if err != nil {
+			t.Fatalf("Failed to create tiered cache: %v", err)
+		}
+		t.Cleanup(func() { tiered.Close() })
+		replicas[i] = tiered
+	}
+
+	replicas[0].Set(ctx, "weather:jakarta", &models.WeatherData{City: "Jakarta"})
+	if data, _ := replicas[1].Get(ctx, "weather:jakarta"); data == nil {
+		t.Fatal("Expected replica B to read through to Redis")
+	}
+
+	if deleted, err := replicas[0].DeletePrefix(ctx, "weather:"); deleted != 1 || err != nil {
+		t.Fatalf("Expected 1 key purged, got %d, %v", deleted, err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if data, _ := replicas[1].Get(ctx, "weather:jakarta"); data == nil {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected replica B's L1 entry to be dropped by replica A's purge")
+}
+
+func TestRedisCacheStartsDegradedWithoutRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	redisCache, err := cache.NewRedisCache(cache.RedisConfig{Addr: addr}, time.Minute)
+	if err != nil {
+		t.Fatalf("Expected to start without Redis, got %v", err)
+	}
+	defer redisCache.Close()
+	if health := redisCache.Health(); health.Healthy || health.DegradedSince == nil {
+		t.Errorf("Expected a degraded cache, got %+v", health)
+	}
+	if _, err := redisCache.Get(context.Background(), "jakarta"); !errors.Is(err, cache.ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got %v", err)
+	}
+}
+
+func TestRedisCacheRecoversAfterOutage(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	redisCache := newTestRedisCache(t, server)
+
+	server.Close()
+	if _, err := redisCache.Get(ctx, "jakarta"); err == nil {
+		t.Fatal("Expected an error while Redis is down")
+	}
+	if redisCache.Health().Healthy {
+		t.Fatal("Expected the outage to mark the cache degraded")
+	}
+	if err := redisCache.Set(ctx, "jakarta", &models.WeatherData{City: "Jakarta"}); !errors.Is(err, cache.ErrUnavailable) {
+		t.Errorf("Expected writes to fail fast while degraded, got %v", err)
+	}
+
+	server.Restart()
+	if err := redisCache.Ping(ctx); err != nil {
+		t.Fatalf("Expected to reconnect, got %v", err)
+	}
+	if !redisCache.Health().Healthy {
+		t.Error("Expected the cache to be healthy again")
+	}
+	if err := redisCache.Set(ctx, "jakarta", &models.WeatherData{City: "Jakarta"}); err != nil {
+		t.Errorf("Expected writes to work again, got %v", err)
+	}
+}
+
+func TestRedisCacheHonoursURLDatabase(t *testing.T) {
+	server := miniredis.RunT(t)
+	redisCache, err := cache.NewRedisCache(cache.RedisConfig{URL: "redis://" + server.Addr() + "/2"}, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to connect to miniredis: %v", err)
+	}
+	defer redisCache.Close()
+
+	if err := redisCache.Set(context.Background(), "jakarta", &models.WeatherData{City: "Jakarta"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if !server.DB(2).Exists("jakarta") || server.DB(0).Exists("jakarta") {
+		t.Error("Expected the entry in database 2 only")
+	}
+}
+
+func TestRedisCacheRejectsInvalidConfig(t *testing.T) {
+	configs := map[string]cache.RedisConfig{
+		"bad scheme":          {URL: "http://localhost:6379"},
+		"unknown mode":        {Mode: "replicated"},
+		"sentinel w/o master": {Mode: cache.RedisSentinel, Addrs: []string{"localhost:26379"}},
+		"cluster database":    {Mode: cache.RedisCluster, URL: "redis://localhost:6379/3"},
+	}
+	for name, config := range configs {
+		if redisCache, err := cache.NewRedisCache(config, time.Minute); err == nil {
+			redisCache.Close()
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,579 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/api/handlers"
+	"github.com/devonphone/weather-aggregator/internal/cache"
+	"github.com/devonphone/weather-aggregator/internal/location"
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"github.com/devonphone/weather-aggregator/internal/providers"
+	ratelimit "github.com/devonphone/weather-aggregator/internal/rate_limit"
+	"github.com/devonphone/weather-aggregator/internal/stats"
+)
+
+// stubProvider answers with a fixed result or error after an optional delay.
+type stubProvider struct {
+	name  string
+	delay time.Duration
+	data  *models.WeatherData
+	err   error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *stubProvider) GetWeather(ctx context.Context, city string) (*models.WeatherData, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	data := *p.data
+	data.City = city
+	data.Source = p.name
+	data.Timestamp = time.Now()
+	return &data, nil
+}
+
+func (p *stubProvider) GetProviderName() string {
+	return p.name
+}
+
+func (p *stubProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+// mapCache is a minimal in-memory cache.Cache for handler tests.
+type mapCache struct {
+	mu       sync.Mutex
+	entries  map[string]models.WeatherData
+	notFound map[string]time.Time
+}
+
+func newMapCache() *mapCache {
+	return &mapCache{
+		entries:  make(map[string]models.WeatherData),
+		notFound: make(map[string]time.Time),
+	}
+}
+
+func (c *mapCache) Get(ctx context.Context, key string) (*models.WeatherData, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if expiresAt, ok := c.notFound[key]; ok && time.Now().Before(expiresAt) {
+		return nil, cache.ErrNotFound
+	}
+	data, ok := c.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	data.Cached = true
+	return &data, nil
+}
+
+func (c *mapCache) Set(ctx context.Context, key string, value *models.WeatherData) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := *value
+	if entry.CachedAt == nil {
+		now := time.Now()
+		entry.CachedAt = &now
+	}
+	c.entries[key] = entry
+	delete(c.notFound, key)
+	return nil
+}
+
+func (c *mapCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+	c.notFound[key] = time.Now().Add(ttl)
+	return nil
+}
+
+func (c *mapCache) Delete(ctx context.Context, key string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, cached := c.entries[key]
+	_, notFound := c.notFound[key]
+	delete(c.entries, key)
+	delete(c.notFound, key)
+	if cached || notFound {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (c *mapCache) Keys(ctx context.Context, pattern string, limit int) ([]string, error) {
+	return nil, nil
+}
+
+func (c *mapCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return 0, nil
+}
+
+func (c *mapCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	return 0, nil
+}
+
+func newTestHandler(providerList []providers.WeatherProvider, opts handlers.WeatherHandlerOptions) *handlers.WeatherHandler {
+	return handlers.NewWeatherHandler(
+		providerList,
+		newMapCache(),
+		ratelimit.NewTokenBucketLimiter(100, time.Minute),
+		stats.NewStatsTracker(),
+		opts,
+	)
+}
+
+func getWeather(handler *handlers.WeatherHandler, city string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/weather?city="+city, nil)
+	handler.GetWeather(recorder, request)
+	return recorder
+}
+
+func TestHandlerFallsThroughFailingProvider(t *testing.T) {
+	failing := &stubProvider{name: "Failing", err: errors.New("401 unauthorized")}
+	slow := &stubProvider{name: "Slow", delay: 50 * time.Millisecond, data: &models.WeatherData{Temperature: 28}}
+
+	handler := newTestHandler([]providers.WeatherProvider{failing, slow}, handlers.WeatherHandlerOptions{})
+
+	recorder := getWeather(handler, "Jakarta")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200 despite one failing provider, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var data models.WeatherData
+	if err := json.NewDecoder(recorder.Body).Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	if data.Source != "Slow" {
+		t.Errorf("Expected result from Slow, got %s", data.Source)
+	}
+}
+
+func TestHandlerReportsEveryProviderFailure(t *testing.T) {
+	handler := newTestHandler([]providers.WeatherProvider{
+		&stubProvider{name: "First", err: &providers.HTTPError{API: "First", StatusCode: 401}},
+		&stubProvider{name: "Second", err: &providers.HTTPError{API: "Second", StatusCode: 429}},
+	}, handlers.WeatherHandlerOptions{})
+
+	recorder := getWeather(handler, "Jakarta")
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503, got %d", recorder.Code)
+	}
+
+	body := recorder.Body.String()
+	for _, reason := range []string{"First: First API error: 401", "Second: Second API error: 429"} {
+		if !strings.Contains(body, reason) {
+			t.Errorf("Expected response to contain %q, got %s", reason, body)
+		}
+	}
+}
+
+func TestSortByPriority(t *testing.T) {
+	a := &stubProvider{name: "A"}
+	b := &stubProvider{name: "B"}
+	c := &stubProvider{name: "C"}
+
+	sorted := providers.SortByPriority([]providers.WeatherProvider{a, b, c}, []string{"C", "A"})
+
+	var names []string
+	for _, provider := range sorted {
+		names = append(names, provider.GetProviderName())
+	}
+	if strings.Join(names, ",") != "C,A,B" {
+		t.Errorf("Expected order C,A,B, got %v", names)
+	}
+}
+
+func TestHandlerMapsProviderErrors(t *testing.T) {
+	cases := []struct {
+		name     string
+		errs     []error
+		expected int
+	}{
+		{"all not found", []error{
+			&providers.HTTPError{API: "A", StatusCode: 404},
+			fmt.Errorf("%w: no match", providers.ErrLocationNotFound),
+		}, http.StatusNotFound},
+		{"bad credentials", []error{
+			&providers.HTTPError{API: "A", StatusCode: 401},
+			fmt.Errorf("%w: truncated body", providers.ErrBadResponse),
+		}, http.StatusBadGateway},
+		{"one unavailable", []error{
+			&providers.HTTPError{API: "A", StatusCode: 404},
+			&providers.HTTPError{API: "B", StatusCode: 503},
+		}, http.StatusServiceUnavailable},
+		{"quota and open breaker", []error{
+			&providers.HTTPError{API: "A", StatusCode: 403, Kind: providers.ErrQuotaExceeded},
+			providers.ErrCircuitOpen,
+		}, http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var providerList []providers.WeatherProvider
+			for i, err := range tc.errs {
+				providerList = append(providerList, &stubProvider{name: fmt.Sprintf("P%d", i), err: err})
+			}
+			handler := newTestHandler(providerList, handlers.WeatherHandlerOptions{})
+
+			if recorder := getWeather(handler, "Atlantis"); recorder.Code != tc.expected {
+				t.Errorf("Expected %d, got %d: %s", tc.expected, recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestHandlerHedgedFetchSkipsBackupWhenPrimaryIsFast(t *testing.T) {
+	primary := &stubProvider{name: "Primary", delay: 5 * time.Millisecond, data: &models.WeatherData{Temperature: 30}}
+	backup := &stubProvider{name: "Backup", data: &models.WeatherData{Temperature: 31}}
+
+	handler := newTestHandler([]providers.WeatherProvider{primary, backup}, handlers.WeatherHandlerOptions{
+		FetchMode:  handlers.FetchHedged,
+		HedgeDelay: 200 * time.Millisecond,
+	})
+
+	if recorder := getWeather(handler, "Jakarta"); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+	if backup.Calls() != 0 {
+		t.Errorf("Expected backup provider not to be called, got %d calls", backup.Calls())
+	}
+}
+
+func TestHandlerHedgedFetchCallsBackupWhenPrimaryIsSlow(t *testing.T) {
+	primary := &stubProvider{name: "Primary", delay: time.Second, data: &models.WeatherData{Temperature: 30}}
+	backup := &stubProvider{name: "Backup", data: &models.WeatherData{Temperature: 31}}
+
+	handler := newTestHandler([]providers.WeatherProvider{primary, backup}, handlers.WeatherHandlerOptions{
+		FetchMode:  handlers.FetchHedged,
+		HedgeDelay: 20 * time.Millisecond,
+	})
+
+	start := time.Now()
+	recorder := getWeather(handler, "Jakarta")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected hedged answer well before the slow primary, took %s", elapsed)
+	}
+
+	var data models.WeatherData
+	json.NewDecoder(recorder.Body).Decode(&data)
+	if data.Source != "Backup" {
+		t.Errorf("Expected result from Backup, got %s", data.Source)
+	}
+}
+
+func TestHandlerHedgedFetchFallsBackImmediatelyOnError(t *testing.T) {
+	primary := &stubProvider{name: "Primary", err: &providers.HTTPError{API: "Primary", StatusCode: 401}}
+	backup := &stubProvider{name: "Backup", data: &models.WeatherData{Temperature: 31}}
+
+	handler := newTestHandler([]providers.WeatherProvider{primary, backup}, handlers.WeatherHandlerOptions{
+		FetchMode:  handlers.FetchHedged,
+		HedgeDelay: time.Minute,
+	})
+
+	if recorder := getWeather(handler, "Jakarta"); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from the backup, got %d", recorder.Code)
+	}
+}
+
+func TestHandlerCoalescesConcurrentMisses(t *testing.T) {
+	provider := &stubProvider{name: "Slow", delay: 100 * time.Millisecond, data: &models.WeatherData{Temperature: 30}}
+	tracker := stats.NewStatsTracker()
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		newMapCache(),
+		ratelimit.NewTokenBucketLimiter(100, time.Minute),
+		tracker,
+		handlers.WeatherHandlerOptions{},
+	)
+
+	cities := []string{"Jakarta", "jakarta", "%20JAKARTA%20"}
+	var wg sync.WaitGroup
+	for i := 0; i < 9; i++ {
+		wg.Add(1)
+		go func(city string) {
+			defer wg.Done()
+			if recorder := getWeather(handler, city); recorder.Code != http.StatusOK {
+				t.Errorf("Expected 200, got %d", recorder.Code)
+			}
+		}(cities[i%len(cities)])
+	}
+	wg.Wait()
+
+	if provider.Calls() != 1 {
+		t.Errorf("Expected one upstream fetch, got %d", provider.Calls())
+	}
+	if coalesced := tracker.GetStats().CoalescedRequests; coalesced != 8 {
+		t.Errorf("Expected 8 coalesced requests, got %d", coalesced)
+	}
+}
+
+func cachedEntry(temperature float64, age time.Duration) *models.WeatherData {
+	cachedAt := time.Now().Add(-age)
+	return &models.WeatherData{City: "Jakarta", Temperature: temperature, Source: "Cached", CachedAt: &cachedAt}
+}
+
+func TestHandlerServesStaleWhileRevalidating(t *testing.T) {
+	provider := &stubProvider{name: "Fresh", data: &models.WeatherData{Temperature: 31}}
+	weatherCache := newMapCache()
+	weatherCache.Set(context.Background(), location.QueryKey("Jakarta"), cachedEntry(25, 2*time.Minute))
+
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		weatherCache,
+		ratelimit.NewTokenBucketLimiter(100, time.Minute),
+		stats.NewStatsTracker(),
+		handlers.WeatherHandlerOptions{
+			FreshFor:             time.Minute,
+			StaleWhileRevalidate: 5 * time.Minute,
+		},
+	)
+
+	recorder := getWeather(handler, "Jakarta")
+	var data models.WeatherData
+	json.NewDecoder(recorder.Body).Decode(&data)
+	if !data.Stale || data.Temperature != 25 || data.AgeSeconds < 120 {
+		t.Errorf("Expected the stale entry with its age, got %+v", data)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if cached, _ := weatherCache.Get(context.Background(), location.QueryKey("Jakarta")); cached.Temperature == 31 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected the entry to be refreshed in the background")
+}
+
+func TestHandlerSkippedRevalidationIsNotARateLimitHit(t *testing.T) {
+	provider := &stubProvider{name: "Fresh", data: &models.WeatherData{Temperature: 31}}
+	weatherCache := newMapCache()
+	weatherCache.Set(context.Background(), location.QueryKey("Jakarta"), cachedEntry(25, 2*time.Minute))
+
+	limiter := ratelimit.NewTokenBucketLimiter(1, time.Minute)
+	limiter.Allow()
+	statsTracker := stats.NewStatsTracker()
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		weatherCache,
+		limiter,
+		statsTracker,
+		handlers.WeatherHandlerOptions{
+			FreshFor:             time.Minute,
+			StaleWhileRevalidate: 5 * time.Minute,
+		},
+	)
+
+	if recorder := getWeather(handler, "Jakarta"); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the stale entry to be served, got %d", recorder.Code)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if hits := statsTracker.GetStats().RateLimitHits; hits != 0 || provider.Calls() != 0 {
+		t.Errorf("Expected the skipped refresh to neither count as a rate limit hit nor call the provider, got %d hits and %d calls", hits, provider.Calls())
+	}
+}
+
+func TestHandlerServesStaleIfProvidersFail(t *testing.T) {
+	provider := &stubProvider{name: "Down", err: &providers.HTTPError{API: "Down", StatusCode: 503}}
+	weatherCache := newMapCache()
+	weatherCache.Set(context.Background(), location.QueryKey("Jakarta"), cachedEntry(25, 20*time.Minute))
+
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		weatherCache,
+		ratelimit.NewTokenBucketLimiter(100, time.Minute),
+		stats.NewStatsTracker(),
+		handlers.WeatherHandlerOptions{
+			FreshFor:             time.Minute,
+			StaleWhileRevalidate: 5 * time.Minute,
+			StaleIfError:         time.Hour,
+		},
+	)
+
+	recorder := getWeather(handler, "Jakarta")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected stale data instead of an error, got %d", recorder.Code)
+	}
+	var data models.WeatherData
+	json.NewDecoder(recorder.Body).Decode(&data)
+	if !data.Stale || data.Temperature != 25 {
+		t.Errorf("Expected the stale entry, got %+v", data)
+	}
+	if provider.Calls() != 1 {
+		t.Errorf("Expected a synchronous refresh attempt, got %d calls", provider.Calls())
+	}
+}
+
+func TestHandlerCachesUnknownLocations(t *testing.T) {
+	notFound := &providers.HTTPError{API: "Stub", StatusCode: 404}
+	first := &stubProvider{name: "First", err: notFound}
+	second := &stubProvider{name: "Second", err: notFound}
+	tracker := stats.NewStatsTracker()
+
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{first, second},
+		newMapCache(),
+		ratelimit.NewTokenBucketLimiter(100, time.Minute),
+		tracker,
+		handlers.WeatherHandlerOptions{NotFoundTTL: time.Minute},
+	)
+
+	for i := 0; i < 3; i++ {
+		if recorder := getWeather(handler, "Jakartaa"); recorder.Code != http.StatusNotFound {
+			t.Fatalf("Expected 404 on request %d, got %d", i+1, recorder.Code)
+		}
+	}
+	if first.Calls() != 1 || second.Calls() != 1 {
+		t.Errorf("Expected only the first request to reach the providers, got %d and %d calls", first.Calls(), second.Calls())
+	}
+	if hits := tracker.GetStats().NegativeCacheHits; hits != 2 {
+		t.Errorf("Expected 2 negative cache hits, got %d", hits)
+	}
+}
+
+func TestHandlerDoesNotCacheTransientFailures(t *testing.T) {
+	notFound := &stubProvider{name: "NotFound", err: &providers.HTTPError{API: "Stub", StatusCode: 404}}
+	down := &stubProvider{name: "Down", err: &providers.HTTPError{API: "Stub", StatusCode: 503}}
+	handler := newTestHandler(
+		[]providers.WeatherProvider{notFound, down},
+		handlers.WeatherHandlerOptions{NotFoundTTL: time.Minute},
+	)
+
+	getWeather(handler, "Jakarta")
+	getWeather(handler, "Jakarta")
+	if down.Calls() != 2 {
+		t.Errorf("Expected a partial not found to be retried, got %d calls", down.Calls())
+	}
+}
+
+// failingCache fails every operation, like a cache whose backend is down.
+type failingCache struct{}
+
+func (failingCache) Get(ctx context.Context, key string) (*models.WeatherData, error) {
+	return nil, cache.ErrUnavailable
+}
+
+func (failingCache) Set(ctx context.Context, key string, value *models.WeatherData) error {
+	return cache.ErrUnavailable
+}
+
+func (failingCache) SetNotFound(ctx context.Context, key string, ttl time.Duration) error {
+	return cache.ErrUnavailable
+}
+
+func (failingCache) Delete(ctx context.Context, key string) (int, error) {
+	return 0, cache.ErrUnavailable
+}
+
+func (failingCache) Keys(ctx context.Context, pattern string, limit int) ([]string, error) {
+	return nil, cache.ErrUnavailable
+}
+
+func (failingCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return 0, cache.ErrUnavailable
+}
+
+func (failingCache) DeletePrefix(ctx context.Context, prefix string) (int, error) {
+	return 0, cache.ErrUnavailable
+}
+
+func TestHandlerBypassesUnavailableCache(t *testing.T) {
+	provider := &stubProvider{name: "Primary", data: &models.WeatherData{Temperature: 30}}
+	tracker := stats.NewStatsTracker()
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		failingCache{},
+		ratelimit.NewTokenBucketLimiter(100, time.Minute),
+		tracker,
+		handlers.WeatherHandlerOptions{},
+	)
+
+	if recorder := getWeather(handler, "Jakarta"); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the providers to answer, got %d", recorder.Code)
+	}
+
+	// One failed lookup and one failed write, and no miss
+	got := tracker.GetStats()
+	if got.CacheErrors != 2 || got.CacheMisses != 0 {
+		t.Errorf("Expected 2 cache errors and no misses, got %d and %d", got.CacheErrors, got.CacheMisses)
+	}
+}
+
+// springfieldProvider resolves both Springfields to the same name and
+// country, as real providers do, but with their own coordinates.
+type springfieldProvider struct{}
+
+func (springfieldProvider) GetWeather(ctx context.Context, city string) (*models.WeatherData, error) {
+	data := &models.WeatherData{
+		City:        "Springfield",
+		Country:     "United States of America",
+		Source:      "Springfield",
+		Temperature: 10,
+		Coordinates: &models.Coordinates{Latitude: 39.80, Longitude: -89.64},
+		Timestamp:   time.Now(),
+	}
+	if strings.HasSuffix(city, "MO") {
+		data.Temperature = 30
+		data.Coordinates = &models.Coordinates{Latitude: 37.22, Longitude: -93.29}
+	}
+	return data, nil
+}
+
+func (springfieldProvider) GetProviderName() string {
+	return "Springfield"
+}
+
+func TestHandlerKeepsSameNamedCitiesApart(t *testing.T) {
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{springfieldProvider{}},
+		cache.NewMemoryCache(100, time.Hour),
+		ratelimit.NewTokenBucketLimiter(100, time.Minute),
+		stats.NewStatsTracker(),
+		handlers.WeatherHandlerOptions{},
+	)
+
+	for _, city := range []string{"Springfield, IL", "Springfield, MO", "Springfield, IL"} {
+		recorder := getWeather(handler, url.QueryEscape(city))
+		var data models.WeatherData
+		if err := json.NewDecoder(recorder.Body).Decode(&data); err != nil {
+			t.Fatalf("Failed to decode response for %s: %v", city, err)
+		}
+		want := 10.0
+		if strings.HasSuffix(city, "MO") {
+			want = 30
+		}
+		if data.Temperature != want {
+			t.Errorf("Expected %v for %s, got %v", want, city, data.Temperature)
+		}
+	}
+}
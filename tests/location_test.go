@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/devonphone/weather-aggregator/internal/location"
+	"github.com/devonphone/weather-aggregator/internal/models"
+)
+
+func TestNormalizeFoldsSpellingVariants(t *testing.T) {
+	variants := []string{"Jakarta", "jakarta", " JAKARTA ", "Ｊａｋａｒｔａ", "jakarta\t"}
+	for _, variant := range variants {
+		if got := location.Normalize(variant); got != "jakarta" {
+			t.Errorf("Expected %q to normalize to jakarta, got %q", variant, got)
+		}
+	}
+
+	// Precomposed and decomposed accents must produce the same key
+	if location.QueryKey("São Paulo") != location.QueryKey("São  paulo") {
+		t.Error("Expected NFC and NFD spellings to share a key")
+	}
+	if location.Normalize("Straße") != location.Normalize("STRASSE") {
+		t.Error("Expected full case folding")
+	}
+}
+
+func TestQueryKeyIsVersioned(t *testing.T) {
+	if key := location.QueryKey("Jakarta"); key != "weather:"+location.KeyVersion+":q:jakarta" {
+		t.Errorf("Unexpected key format %q", key)
+	}
+}
+
+func TestResolverLearnsAliases(t *testing.T) {
+	resolver := location.NewResolver(10)
+
+	data := &models.WeatherData{
+		City:        "Jakarta",
+		Country:     "Indonesia",
+		Coordinates: &models.Coordinates{Latitude: -6.2146, Longitude: 106.8451},
+	}
+	keys := resolver.Learn("Jakarta, ID", data)
+	if len(keys) != 2 {
+		t.Fatalf("Expected query and resolved keys, got %v", keys)
+	}
+
+	resolved := keys[1]
+	if resolved != "weather:"+location.KeyVersion+":geo:-6.21,106.85" {
+		t.Errorf("Unexpected resolved key %q", resolved)
+	}
+	if key := resolver.Key(" jakarta, id "); key != resolved {
+		t.Errorf("Expected a known query to map to its resolved key, got %q", key)
+	}
+	if key := resolver.Key("Djakarta"); key != location.QueryKey("Djakarta") {
+		t.Errorf("Expected an unknown query to use its own key, got %q", key)
+	}
+}
+
+func TestResolvedKeyUsesCoordinates(t *testing.T) {
+	key, ok := location.ResolvedKey(&models.WeatherData{
+		City:        "Jakarta",
+		Coordinates: &models.Coordinates{Latitude: -6.2146, Longitude: 106.8451},
+	})
+	if !ok || key != "weather:"+location.KeyVersion+":geo:-6.21,106.85" {
+		t.Errorf("Expected a coordinate key, got %q", key)
+	}
+
+	if _, ok := location.ResolvedKey(&models.WeatherData{City: "Jakarta", Country: "Indonesia"}); ok {
+		t.Error("Expected no resolved key without coordinates")
+	}
+}
+
+func TestResolverKeepsSameNamedPlacesApart(t *testing.T) {
+	resolver := location.NewResolver(10)
+
+	illinois := resolver.Learn("Springfield, IL", &models.WeatherData{
+		City:        "Springfield",
+		Country:     "United States of America",
+		Coordinates: &models.Coordinates{Latitude: 39.80, Longitude: -89.64},
+	})
+	missouri := resolver.Learn("Springfield, MO", &models.WeatherData{
+		City:        "Springfield",
+		Country:     "United States of America",
+		Coordinates: &models.Coordinates{Latitude: 37.22, Longitude: -93.29},
+	})
+
+	if illinois[1] == missouri[1] {
+		t.Fatalf("Expected distinct places to get distinct keys, both got %q", illinois[1])
+	}
+	if key := resolver.Key("Springfield, IL"); key != illinois[1] {
+		t.Errorf("Expected Springfield, IL to keep its own key, got %q", key)
+	}
+}
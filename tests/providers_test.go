@@ -0,0 +1,280 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/devonphone/weather-aggregator/internal/providers"
+)
+
+const openMeteoGeocodingJSON = `{
+  "results": [
+    {
+      "id": 1642911,
+      "name": "Jakarta",
+      "latitude": -6.21462,
+      "longitude": 106.84513,
+      "country_code": "ID",
+      "country": "Indonesia"
+    }
+  ],
+  "generationtime_ms": 0.71
+}`
+
+const openMeteoForecastJSON = `{
+  "latitude": -6.25,
+  "longitude": 106.875,
+  "timezone": "GMT",
+  "current_units": {
+    "time": "iso8601",
+    "interval": "seconds",
+    "temperature_2m": "°C",
+    "relative_humidity_2m": "%",
+    "weather_code": "wmo code"
+  },
+  "current": {
+    "time": "2024-12-26T06:00",
+    "interval": 900,
+    "temperature_2m": 31.4,
+    "relative_humidity_2m": 66,
+    "weather_code": 80
+  }
+}`
+
+func newOpenMeteoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("name") != "Jakarta" {
+			w.Write([]byte(`{"generationtime_ms": 0.5}`))
+			return
+		}
+		w.Write([]byte(openMeteoGeocodingJSON))
+	})
+	mux.HandleFunc("/v1/forecast", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("latitude") != "-6.21462" || r.URL.Query().Get("longitude") != "106.84513" {
+			t.Errorf("Unexpected coordinates in query %s", r.URL.RawQuery)
+		}
+		w.Write([]byte(openMeteoForecastJSON))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOpenMeteoProvider(t *testing.T) {
+	server := newOpenMeteoServer(t)
+	provider := providers.NewOpenMeteoProviderWithURLs(server.URL+"/v1/forecast", server.URL+"/v1/search")
+
+	data, err := provider.GetWeather(context.Background(), "Jakarta")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if data.City != "Jakarta" {
+		t.Errorf("Expected city Jakarta, got %s", data.City)
+	}
+	if data.Temperature != 31.4 {
+		t.Errorf("Expected temperature 31.4, got %f", data.Temperature)
+	}
+	if data.Humidity != 66 {
+		t.Errorf("Expected humidity 66, got %d", data.Humidity)
+	}
+	if data.Condition != "Slight rain showers" {
+		t.Errorf("Expected condition from WMO code 80, got %q", data.Condition)
+	}
+	if data.Source != "OpenMeteo" {
+		t.Errorf("Expected source OpenMeteo, got %s", data.Source)
+	}
+}
+
+func TestOpenMeteoProviderUnknownCity(t *testing.T) {
+	server := newOpenMeteoServer(t)
+	provider := providers.NewOpenMeteoProviderWithURLs(server.URL+"/v1/forecast", server.URL+"/v1/search")
+
+	if _, err := provider.GetWeather(context.Background(), "Atlantis"); !errors.Is(err, providers.ErrLocationNotFound) {
+		t.Fatalf("Expected ErrLocationNotFound for a city the geocoder cannot resolve, got %v", err)
+	}
+}
+
+const nwsGeocodingJSON = `{
+  "results": [
+    {
+      "name": "Washington",
+      "latitude": 38.89511,
+      "longitude": -77.03637,
+      "country_code": "US",
+      "country": "United States"
+    }
+  ]
+}`
+
+const nwsPointsJSON = `{
+  "properties": {
+    "gridId": "LWX",
+    "gridX": 97,
+    "gridY": 71,
+    "observationStations": "https://api.weather.gov/gridpoints/LWX/97,71/stations"
+  }
+}`
+
+const nwsStationsJSON = `{
+  "features": [
+    {"properties": {"stationIdentifier": "KDCA", "name": "Washington/Reagan National Airport"}},
+    {"properties": {"stationIdentifier": "KADW", "name": "Joint Base Andrews"}}
+  ]
+}`
+
+const nwsObservationJSON = `{
+  "properties": {
+    "station": "https://api.weather.gov/stations/KDCA",
+    "textDescription": "Partly Cloudy",
+    "temperature": {"unitCode": "wmoUnit:degC", "value": 4.4, "qualityControl": "V"},
+    "relativeHumidity": {"unitCode": "wmoUnit:percent", "value": 55.63, "qualityControl": "V"}
+  }
+}`
+
+func TestNWSProviderCachesGridpoint(t *testing.T) {
+	var pointsCalls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(nwsGeocodingJSON))
+	})
+	mux.HandleFunc("/points/38.8951,-77.0364", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pointsCalls, 1)
+		if r.Header.Get("User-Agent") != "weather-aggregator-tests" {
+			t.Errorf("Expected configured User-Agent, got %q", r.Header.Get("User-Agent"))
+		}
+		w.Write([]byte(nwsPointsJSON))
+	})
+	mux.HandleFunc("/gridpoints/LWX/97,71/stations", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(nwsStationsJSON))
+	})
+	mux.HandleFunc("/stations/KDCA/observations/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(nwsObservationJSON))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := providers.NewNWSProviderWithURLs(server.URL, server.URL+"/v1/search", "weather-aggregator-tests")
+
+	for i := 0; i < 2; i++ {
+		data, err := provider.GetWeather(context.Background(), "Washington")
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if data.Temperature != 4.4 {
+			t.Errorf("Expected temperature 4.4, got %f", data.Temperature)
+		}
+		if data.Humidity != 56 {
+			t.Errorf("Expected humidity 56, got %d", data.Humidity)
+		}
+		if data.Condition != "Partly Cloudy" {
+			t.Errorf("Expected condition Partly Cloudy, got %q", data.Condition)
+		}
+	}
+
+	if calls := atomic.LoadInt32(&pointsCalls); calls != 1 {
+		t.Errorf("Expected /points to be called once, got %d", calls)
+	}
+}
+
+func TestNWSProviderCachesUncoveredLocations(t *testing.T) {
+	var searchCalls, pointsCalls int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&searchCalls, 1)
+		w.Write([]byte(nwsGeocodingJSON))
+	})
+	mux.HandleFunc("/points/", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pointsCalls, 1)
+		http.Error(w, `{"title": "Data Unavailable For Requested Point"}`, http.StatusNotFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	provider := providers.NewNWSProviderWithURLs(server.URL, server.URL+"/v1/search", "weather-aggregator-tests")
+
+	for i := 0; i < 2; i++ {
+		if _, err := provider.GetWeather(context.Background(), "Paris"); !errors.Is(err, providers.ErrLocationNotFound) {
+			t.Fatalf("Expected ErrLocationNotFound, got %v", err)
+		}
+	}
+	if searches, points := atomic.LoadInt32(&searchCalls), atomic.LoadInt32(&pointsCalls); searches != 1 || points != 1 {
+		t.Errorf("Expected the uncovered location to be looked up once, got %d geocodes and %d /points calls", searches, points)
+	}
+}
+
+const weatherAPICurrentJSON = `{
+  "location": {"name": "New York", "region": "New York", "country": "United States of America", "lat": 40.71, "lon": -74.01},
+  "current": {"temp_c": 12.2, "humidity": 62, "condition": {"text": "Overcast"}}
+}`
+
+func TestWeatherAPIProviderErrorTaxonomy(t *testing.T) {
+	cases := []struct {
+		status   int
+		body     string
+		expected error
+	}{
+		{400, `{"error":{"code":1006,"message":"No matching location found."}}`, providers.ErrLocationNotFound},
+		{401, `{"error":{"code":2006,"message":"API key provided is invalid"}}`, providers.ErrUnauthorized},
+		{403, `{"error":{"code":2007,"message":"API key has exceeded calls per month quota."}}`, providers.ErrQuotaExceeded},
+		{502, `<html>Bad Gateway</html>`, providers.ErrUpstreamUnavailable},
+	}
+
+	for _, tc := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.status)
+			w.Write([]byte(tc.body))
+		}))
+
+		provider := providers.NewWeatherAPIProviderWithURL("key", server.URL)
+		_, err := provider.GetWeather(context.Background(), "Jakarta")
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("Expected %v for status %d, got %v", tc.expected, tc.status, err)
+		}
+		server.Close()
+	}
+}
+
+const openWeatherCurrentJSON = `{
+  "coord": {"lon": -74.006, "lat": 40.7143},
+  "weather": [{"id": 804, "main": "Clouds", "description": "overcast clouds"}],
+  "main": {"temp": 12.2, "humidity": 62}
+}`
+
+func TestProvidersEscapeCityInQuery(t *testing.T) {
+	cases := []struct {
+		name     string
+		body     string
+		provider func(baseURL string) providers.WeatherProvider
+	}{
+		{"WeatherAPI", weatherAPICurrentJSON, func(baseURL string) providers.WeatherProvider {
+			return providers.NewWeatherAPIProviderWithURL("key", baseURL)
+		}},
+		{"OpenWeather", openWeatherCurrentJSON, func(baseURL string) providers.WeatherProvider {
+			return providers.NewOpenWeatherProviderWithURL("key", baseURL)
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				query := r.URL.Query()
+				if query.Get("q") != "New York&units=imperial" || query.Get("units") == "imperial" {
+					t.Errorf("Expected the city to be a single escaped parameter, got query %s", r.URL.RawQuery)
+				}
+				w.Write([]byte(tc.body))
+			}))
+			defer server.Close()
+
+			if _, err := tc.provider(server.URL).GetWeather(context.Background(), "New York&units=imperial"); err != nil {
+				t.Fatalf("Expected no error for a multi-word city, got %v", err)
+			}
+		})
+	}
+}
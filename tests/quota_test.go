@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devonphone/weather-aggregator/api/handlers"
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"github.com/devonphone/weather-aggregator/internal/providers"
+	"github.com/devonphone/weather-aggregator/internal/quota"
+	ratelimit "github.com/devonphone/weather-aggregator/internal/rate_limit"
+	"github.com/devonphone/weather-aggregator/internal/stats"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestBudget(t *testing.T, server *miniredis.Miniredis, limits string, reserve float64) *quota.RedisBudget {
+	t.Helper()
+	parsed, err := quota.ParseLimits(limits)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return quota.NewRedisBudget(client, parsed, reserve)
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := quota.ParseLimits("OpenWeatherMap:minute=60,day=1000; WeatherAPIMap:month=1000000")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := limits["OpenWeatherMap"]; got[quota.Minute] != 60 || got[quota.Day] != 1000 || len(got) != 2 {
+		t.Errorf("Unexpected OpenWeatherMap limits: %v", got)
+	}
+	if got := limits["WeatherAPIMap"]; got[quota.Month] != 1000000 || len(got) != 1 {
+		t.Errorf("Unexpected WeatherAPIMap limits: %v", got)
+	}
+
+	for _, value := range []string{"OpenWeatherMap", "OpenWeatherMap:hour=10", "OpenWeatherMap:day=0", "OpenWeatherMap:day"} {
+		if _, err := quota.ParseLimits(value); err == nil {
+			t.Errorf("Expected %q to be rejected", value)
+		}
+	}
+}
+
+func TestRedisBudgetKeepsReserve(t *testing.T) {
+	server := miniredis.RunT(t)
+	budget := newTestBudget(t, server, "OpenWeatherMap:day=10", 0.2)
+
+	for i := 0; i < 8; i++ {
+		if err := budget.Spend(context.Background(), "OpenWeatherMap"); err != nil {
+			t.Fatalf("Expected call %d to be within budget, got %v", i+1, err)
+		}
+	}
+	err := budget.Spend(context.Background(), "OpenWeatherMap")
+	if !errors.Is(err, providers.ErrBudgetExhausted) || !errors.Is(err, providers.ErrQuotaExceeded) {
+		t.Fatalf("Expected the reserve to be kept, got %v", err)
+	}
+	if err := budget.Spend(context.Background(), "OpenMeteo"); err != nil {
+		t.Errorf("Expected providers without limits to be unrestricted, got %v", err)
+	}
+}
+
+func TestRedisBudgetChecksEveryWindow(t *testing.T) {
+	server := miniredis.RunT(t)
+	budget := newTestBudget(t, server, "OpenWeatherMap:minute=2,day=100", 0)
+
+	budget.Spend(context.Background(), "OpenWeatherMap")
+	budget.Spend(context.Background(), "OpenWeatherMap")
+	if err := budget.Spend(context.Background(), "OpenWeatherMap"); !errors.Is(err, providers.ErrBudgetExhausted) {
+		t.Fatalf("Expected the minute budget to be exhausted, got %v", err)
+	}
+
+	// A rejected call is not counted against the other windows
+	var minuteKey, dayKey string
+	for _, key := range server.Keys() {
+		switch {
+		case strings.Contains(key, ":minute:"):
+			minuteKey = key
+		case strings.Contains(key, ":day:"):
+			dayKey = key
+		}
+	}
+	if got, _ := server.Get(dayKey); got != "2" {
+		t.Errorf("Expected 2 calls counted for the day, got %q", got)
+	}
+
+	// The minute window expires on its own, the day window does not
+	server.FastForward(2 * time.Minute)
+	if server.Exists(minuteKey) {
+		t.Error("Expected the minute window to expire")
+	}
+	if err := budget.Spend(context.Background(), "OpenWeatherMap"); err != nil {
+		t.Errorf("Expected a new minute window to allow calls, got %v", err)
+	}
+	if got, _ := server.Get(dayKey); got != "3" {
+		t.Errorf("Expected 3 calls counted for the day, got %q", got)
+	}
+}
+
+func TestRedisBudgetFailsOpen(t *testing.T) {
+	server := miniredis.RunT(t)
+	budget := newTestBudget(t, server, "OpenWeatherMap:minute=1", 0)
+
+	server.Close()
+	for i := 0; i < 2; i++ {
+		if err := budget.Spend(context.Background(), "OpenWeatherMap"); err != nil {
+			t.Errorf("Expected calls to be allowed while Redis is down, got %v", err)
+		}
+	}
+}
+
+func TestRedisBudgetSkipsUnresponsiveRedis(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: blackholeRedis(t), ReadTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { client.Close() })
+	budget := quota.NewRedisBudget(client, map[string]quota.Limits{"OpenWeatherMap": {quota.Minute: 1}}, 0)
+
+	budget.Spend(context.Background(), "OpenWeatherMap")
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if err := budget.Spend(context.Background(), "OpenWeatherMap"); err != nil {
+			t.Fatalf("Expected calls to be allowed while Redis is down, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected Redis to be skipped after it timed out, took %v", elapsed)
+	}
+}
+
+func TestCircuitBreakerIgnoresExhaustedBudget(t *testing.T) {
+	server := miniredis.RunT(t)
+	budget := newTestBudget(t, server, "Limited:day=1", 0)
+	provider := &stubProvider{name: "Limited", data: &models.WeatherData{Temperature: 30}}
+	breaker := providers.NewCircuitBreaker(providers.NewBudgetedProvider(provider, budget), providers.CircuitBreakerConfig{
+		FailureThreshold: 1,
+		CoolOff:          time.Minute,
+	})
+
+	breaker.GetWeather(context.Background(), "Jakarta")
+	if _, err := breaker.GetWeather(context.Background(), "Jakarta"); !errors.Is(err, providers.ErrBudgetExhausted) {
+		t.Fatalf("Expected the budget to be exhausted, got %v", err)
+	}
+	if provider.Calls() != 1 {
+		t.Errorf("Expected the provider not to be called over budget, got %d calls", provider.Calls())
+	}
+	if state := breaker.Status().State; state != providers.BreakerClosed {
+		t.Errorf("Expected an exhausted budget not to trip the breaker, got %s", state)
+	}
+}
+
+func TestHandlerShiftsLoadOffExhaustedProvider(t *testing.T) {
+	server := miniredis.RunT(t)
+	budget := newTestBudget(t, server, "Limited:day=1", 0)
+	limited := &stubProvider{name: "Limited", data: &models.WeatherData{Temperature: 30}}
+	other := &stubProvider{name: "Other", data: &models.WeatherData{Temperature: 31}}
+
+	handler := newTestHandler([]providers.WeatherProvider{
+		providers.NewBudgetedProvider(limited, budget),
+		other,
+	}, handlers.WeatherHandlerOptions{FetchMode: handlers.FetchHedged, HedgeDelay: time.Second})
+
+	for _, city := range []string{"Jakarta", "Surabaya"} {
+		if recorder := getWeather(handler, city); recorder.Code != http.StatusOK {
+			t.Fatalf("Expected 200 for %s, got %d: %s", city, recorder.Code, recorder.Body)
+		}
+	}
+	if limited.Calls() != 1 || other.Calls() != 1 {
+		t.Errorf("Expected the second city to go to the other provider, got %d and %d calls", limited.Calls(), other.Calls())
+	}
+}
+
+func TestHandlerCountsOnlyCallsThatReachProviders(t *testing.T) {
+	server := miniredis.RunT(t)
+	budget := newTestBudget(t, server, "Limited:day=1", 0)
+	limited := &stubProvider{name: "Limited", data: &models.WeatherData{Temperature: 30}}
+	statsTracker := stats.NewStatsTracker()
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{providers.NewBudgetedProvider(limited, budget)},
+		newMapCache(),
+		ratelimit.NewTokenBucketLimiter(100, time.Minute),
+		statsTracker,
+		handlers.WeatherHandlerOptions{},
+	)
+
+	getWeather(handler, "Jakarta")
+	getWeather(handler, "Surabaya")
+
+	got := statsTracker.GetStats()
+	if got.ApiCalls != 1 || got.Providers["Limited"].BudgetExhausted != 1 {
+		t.Errorf("Expected 1 API call and 1 refused call, got %d and %d", got.ApiCalls, got.Providers["Limited"].BudgetExhausted)
+	}
+}
@@ -0,0 +1,624 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/devonphone/weather-aggregator/api/handlers"
+	"github.com/devonphone/weather-aggregator/config"
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"github.com/devonphone/weather-aggregator/internal/providers"
+	ratelimit "github.com/devonphone/weather-aggregator/internal/rate_limit"
+	"github.com/devonphone/weather-aggregator/internal/stats"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestKeyedLimiterIsolatesClients(t *testing.T) {
+	limiter := ratelimit.NewKeyedLimiter(2, time.Minute, time.Minute, 10)
+
+	noisy := limiter.Limiter("ip:10.0.0.1")
+	for i := 0; i < 2; i++ {
+		if !noisy.Allow() {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	if noisy.Allow() {
+		t.Error("Expected the noisy client to be limited")
+	}
+	if !limiter.Limiter("ip:10.0.0.2").Allow() {
+		t.Error("Expected other clients to keep their own budget")
+	}
+}
+
+func TestKeyedLimiterEvictsIdleBuckets(t *testing.T) {
+	limiter := ratelimit.NewKeyedLimiter(1, time.Millisecond, 20*time.Millisecond, 10)
+	limiter.Limiter("ip:10.0.0.1")
+	limiter.Limiter("ip:10.0.0.2")
+
+	time.Sleep(30 * time.Millisecond)
+	limiter.Limiter("ip:10.0.0.3")
+	if limiter.Len() != 1 {
+		t.Errorf("Expected idle buckets to be evicted, got %d", limiter.Len())
+	}
+}
+
+func TestKeyedLimiterBoundsClients(t *testing.T) {
+	limiter := ratelimit.NewKeyedLimiter(1, time.Minute, time.Hour, 2)
+	first := limiter.Limiter("ip:10.0.0.1")
+	first.Allow()
+	limiter.Limiter("ip:10.0.0.2")
+	limiter.Limiter("ip:10.0.0.1")
+
+	limiter.Limiter("ip:10.0.0.3")
+	if limiter.Len() != 2 {
+		t.Errorf("Expected at most 2 buckets, got %d", limiter.Len())
+	}
+	if limiter.Limiter("ip:10.0.0.1").Allow() {
+		t.Error("Expected the recently used bucket to be kept")
+	}
+}
+
+func TestClientIdentifier(t *testing.T) {
+	identifier, err := ratelimit.NewClientIdentifier("X-API-Key", []string{"secret-key"}, []string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	request := func(remoteAddr, forwardedFor, apiKey string) *http.Request {
+		r := httptest.NewRequest("GET", "/weather?city=Jakarta", nil)
+		r.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		if apiKey != "" {
+			r.Header.Set("X-API-Key", apiKey)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		want    string
+	}{
+		{"direct client", request("203.0.113.7:5000", "", ""), "ip:203.0.113.7"},
+		{"untrusted peer's header ignored", request("203.0.113.7:5000", "198.51.100.1", ""), "ip:203.0.113.7"},
+		{"trusted proxy", request("10.1.2.3:5000", "198.51.100.1", ""), "ip:198.51.100.1"},
+		{"proxy chain", request("10.1.2.3:5000", "6.6.6.6, 198.51.100.1, 192.168.1.1", ""), "ip:198.51.100.1"},
+		{"only proxies", request("10.1.2.3:5000", "10.9.9.9", ""), "ip:10.9.9.9"},
+	}
+	for _, test := range tests {
+		if got := identifier.Identify(test.request); got != test.want {
+			t.Errorf("%s: expected %s, got %s", test.name, test.want, got)
+		}
+	}
+
+	first := identifier.Identify(request("203.0.113.7:5000", "", "secret-key"))
+	second := identifier.Identify(request("198.51.100.1:5000", "", "secret-key"))
+	if first != second || first == "key:secret-key" {
+		t.Errorf("Expected the same hashed API key identity from any IP, got %s and %s", first, second)
+	}
+	if got := identifier.Identify(request("203.0.113.7:5000", "", "made-up-key")); got != "ip:203.0.113.7" {
+		t.Errorf("Expected an unknown API key to fall back to the IP, got %s", got)
+	}
+
+	if _, err := ratelimit.NewClientIdentifier("", nil, []string{"not-an-ip"}); err == nil {
+		t.Error("Expected an invalid trusted proxy to be rejected")
+	}
+}
+
+func TestHandlerRateLimitsPerClient(t *testing.T) {
+	provider := &stubProvider{name: "Primary", data: &models.WeatherData{Temperature: 30}}
+	identifier, _ := ratelimit.NewClientIdentifier("X-API-Key", []string{"noisy", "quiet"}, nil)
+	handler := newTestHandler([]providers.WeatherProvider{provider}, handlers.WeatherHandlerOptions{
+		ClientLimiter:    ratelimit.NewKeyedLimiter(1, time.Minute, time.Minute, 10),
+		ClientIdentifier: identifier,
+	})
+
+	get := func(city, apiKey string) int {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/weather?city="+city, nil)
+		request.Header.Set("X-API-Key", apiKey)
+		handler.GetWeather(recorder, request)
+		return recorder.Code
+	}
+
+	if code := get("Jakarta", "noisy"); code != http.StatusOK {
+		t.Fatalf("Expected the first request to succeed, got %d", code)
+	}
+	if code := get("Bandung", "noisy"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the noisy client to be limited, got %d", code)
+	}
+	if code := get("Surabaya", "quiet"); code != http.StatusOK {
+		t.Errorf("Expected another client to be unaffected, got %d", code)
+	}
+}
+
+func TestRedisLimiterIsSharedBetweenReplicas(t *testing.T) {
+	server := miniredis.RunT(t)
+	now := time.Now()
+	server.SetTime(now)
+
+	newReplica := func() *ratelimit.RedisLimiter {
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return ratelimit.NewRedisLimiter(client, "global", 3, time.Minute)
+	}
+	replicaA, replicaB := newReplica(), newReplica()
+
+	for i, limiter := range []*ratelimit.RedisLimiter{replicaA, replicaB, replicaA} {
+		if !limiter.Allow() {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+	if replicaB.Allow() {
+		t.Fatal("Expected the shared limit to be exhausted")
+	}
+
+	// One request is replenished every 20 seconds
+	server.SetTime(now.Add(20 * time.Second))
+	if !replicaB.Allow() {
+		t.Error("Expected a request to be allowed after one emission interval")
+	}
+	if replicaA.Allow() {
+		t.Error("Expected only one request to be replenished")
+	}
+}
+
+func TestRedisLimiterKeysClientsSeparately(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	limiter := ratelimit.NewRedisLimiter(client, "client", 1, time.Minute)
+
+	if !limiter.Limiter("ip:10.0.0.1").Allow() || limiter.Limiter("ip:10.0.0.1").Allow() {
+		t.Error("Expected the first client to get exactly one request")
+	}
+	if !limiter.Limiter("ip:10.0.0.2").Allow() {
+		t.Error("Expected the second client to have its own budget")
+	}
+}
+
+func TestRedisLimiterWaitsForNextRequest(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	limiter := ratelimit.NewRedisLimiter(client, "global", 1, 50*time.Millisecond)
+
+	limiter.Allow()
+	start := time.Now()
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if waited := time.Since(start); waited < 30*time.Millisecond {
+		t.Errorf("Expected to wait for the next request, waited %v", waited)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); err == nil {
+		t.Error("Expected Wait to give up when the context ends first")
+	}
+}
+
+func TestRedisLimiterFailsOpen(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	limiter := ratelimit.NewRedisLimiter(client, "global", 1, time.Minute)
+
+	server.Close()
+	if !limiter.Allow() || !limiter.Allow() {
+		t.Error("Expected requests to be allowed while Redis is down")
+	}
+}
+
+func TestTokenBucketLimiterReportsStatus(t *testing.T) {
+	limiter := ratelimit.NewTokenBucketLimiter(2, time.Minute)
+
+	if status := limiter.Status(); status.Limit != 2 || status.Remaining != 2 || status.Reset != 0 {
+		t.Errorf("Expected a full limit, got %+v", status)
+	}
+	limiter.Take()
+	status := limiter.Take()
+	if !status.Allowed || status.Remaining != 0 || status.Reset < 59*time.Second {
+		t.Errorf("Expected the last request to be allowed with a minute to reset, got %+v", status)
+	}
+	status = limiter.Take()
+	if status.Allowed || status.RetryAfter < 29*time.Second || status.RetryAfter > 30*time.Second {
+		t.Errorf("Expected a rejection with 30 seconds to retry, got %+v", status)
+	}
+}
+
+func TestRedisLimiterReportsStatus(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.SetTime(time.Now())
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	limiter := ratelimit.NewRedisLimiter(client, "global", 3, time.Minute)
+
+	if status := limiter.Status(); status.Limit != 3 || status.Remaining != 3 {
+		t.Errorf("Expected a full limit, got %+v", status)
+	}
+	if status := limiter.Take(); status.Remaining != 2 || status.Reset != 20*time.Second {
+		t.Errorf("Expected 2 requests left and 20s to reset, got %+v", status)
+	}
+	limiter.Take()
+	limiter.Take()
+	status := limiter.Take()
+	if status.Allowed || status.Remaining != 0 || status.RetryAfter != 20*time.Second || status.Reset != time.Minute {
+		t.Errorf("Expected a rejection with 20s to retry and a minute to reset, got %+v", status)
+	}
+}
+
+func TestHandlerSetsRateLimitHeaders(t *testing.T) {
+	provider := &stubProvider{name: "Primary", data: &models.WeatherData{Temperature: 30}}
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		newMapCache(),
+		ratelimit.NewTokenBucketLimiter(1, time.Minute),
+		stats.NewStatsTracker(),
+		handlers.WeatherHandlerOptions{},
+	)
+
+	recorder := getWeather(handler, "Jakarta")
+	if recorder.Header().Get("RateLimit-Limit") != "1" || recorder.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected the miss to use up the limit, got headers %v", recorder.Header())
+	}
+
+	// Cache hits are free but still report the limit
+	recorder = getWeather(handler, "Jakarta")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("Expected a cache hit with headers, got %d and %v", recorder.Code, recorder.Header())
+	}
+
+	recorder = getWeather(handler, "Bandung")
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", recorder.Code)
+	}
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "60" {
+		t.Errorf("Expected Retry-After: 60, got %q", retryAfter)
+	}
+	if reset := recorder.Header().Get("RateLimit-Reset"); reset != "60" {
+		t.Errorf("Expected RateLimit-Reset: 60, got %q", reset)
+	}
+}
+
+func newQueueingHandler(limiter ratelimit.RateLimiter, statsTracker *stats.StatsTracker, wait time.Duration, length int) *handlers.WeatherHandler {
+	provider := &stubProvider{name: "Primary", data: &models.WeatherData{Temperature: 30}}
+	return handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		newMapCache(),
+		limiter,
+		statsTracker,
+		handlers.WeatherHandlerOptions{RateLimitQueueWait: wait, RateLimitQueueLength: length},
+	)
+}
+
+func TestHandlerQueuesForRateLimit(t *testing.T) {
+	statsTracker := stats.NewStatsTracker()
+	handler := newQueueingHandler(ratelimit.NewTokenBucketLimiter(1, 50*time.Millisecond), statsTracker, time.Second, 10)
+
+	getWeather(handler, "Jakarta")
+	start := time.Now()
+	if recorder := getWeather(handler, "Bandung"); recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the queued request to succeed, got %d: %s", recorder.Code, recorder.Body)
+	}
+	if waited := time.Since(start); waited < 30*time.Millisecond {
+		t.Errorf("Expected the request to wait for the limit, waited %v", waited)
+	}
+	if got := statsTracker.GetStats(); got.RateLimitQueued != 1 || got.RateLimitHits != 0 {
+		t.Errorf("Expected 1 queued request and no rejections, got %+v", got)
+	}
+}
+
+func TestHandlerRejectsWaitsLongerThanQueueWait(t *testing.T) {
+	handler := newQueueingHandler(ratelimit.NewTokenBucketLimiter(1, time.Minute), stats.NewStatsTracker(), time.Second, 10)
+
+	getWeather(handler, "Jakarta")
+	start := time.Now()
+	if recorder := getWeather(handler, "Bandung"); recorder.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", recorder.Code)
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("Expected an immediate rejection, waited %v", waited)
+	}
+}
+
+func TestHandlerRejectsWhenQueueIsFull(t *testing.T) {
+	handler := newQueueingHandler(ratelimit.NewTokenBucketLimiter(1, 200*time.Millisecond), stats.NewStatsTracker(), time.Second, 1)
+	getWeather(handler, "Jakarta")
+
+	queued := make(chan int)
+	go func() {
+		queued <- getWeather(handler, "Bandung").Code
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if recorder := getWeather(handler, "Surabaya"); recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 with the queue full, got %d", recorder.Code)
+	}
+	if code := <-queued; code != http.StatusOK {
+		t.Errorf("Expected the queued request to succeed, got %d", code)
+	}
+}
+
+func TestHandlerStopsQueueingWhenRequestEnds(t *testing.T) {
+	handler := newQueueingHandler(ratelimit.NewTokenBucketLimiter(1, time.Second), stats.NewStatsTracker(), 2*time.Second, 10)
+	getWeather(handler, "Jakarta")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	recorder := httptest.NewRecorder()
+	start := time.Now()
+	handler.GetWeather(recorder, httptest.NewRequest("GET", "/weather?city=Bandung", nil).WithContext(ctx))
+
+	if recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429, got %d", recorder.Code)
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("Expected the wait to end with the request, waited %v", waited)
+	}
+}
+
+func TestRedisLimiterWaitGivesUpBeforeDeadline(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	limiter := ratelimit.NewRedisLimiter(client, "global", 1, time.Minute)
+
+	limiter.Allow()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := limiter.Wait(ctx); err != ratelimit.ErrWaitExceedsDeadline {
+		t.Errorf("Expected ErrWaitExceedsDeadline, got %v", err)
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("Expected Wait to give up right away, waited %v", waited)
+	}
+}
+
+func TestHandlerCapsClientsWithGlobalLimit(t *testing.T) {
+	provider := &stubProvider{name: "Primary", data: &models.WeatherData{Temperature: 30}}
+	identifier, _ := ratelimit.NewClientIdentifier("X-API-Key", nil, nil)
+	clients := ratelimit.NewKeyedLimiter(1, time.Minute, time.Minute, 10)
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		newMapCache(),
+		ratelimit.NewTokenBucketLimiter(3, time.Minute),
+		stats.NewStatsTracker(),
+		handlers.WeatherHandlerOptions{ClientLimiter: clients, ClientIdentifier: identifier},
+	)
+
+	allowed := 0
+	for i := 0; i < 50; i++ {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", fmt.Sprintf("/weather?city=City%d", i), nil)
+		request.RemoteAddr = fmt.Sprintf("203.0.113.%d:5000", i%5)
+		request.Header.Set("X-API-Key", fmt.Sprintf("made-up-%d", i))
+		handler.GetWeather(recorder, request)
+		if recorder.Code == http.StatusOK {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("Expected the global limit to cap all clients at 3 requests, got %d", allowed)
+	}
+	if clients.Len() != 5 {
+		t.Errorf("Expected made up API keys to be limited by IP, got %d buckets", clients.Len())
+	}
+}
+
+// loadDefaultConfig loads the config from an empty .env with the rate limit
+// variables unset.
+func loadDefaultConfig(t *testing.T) *config.Config {
+	t.Helper()
+	for _, key := range []string{"RATE_LIMIT_REQUESTS", "RATE_LIMIT_DURATION", "RATE_LIMIT_GLOBAL_REQUESTS"} {
+		t.Setenv(key, "")
+	}
+
+	dir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(dir) })
+	tmp := t.TempDir()
+	os.WriteFile(filepath.Join(tmp, ".env"), nil, 0o644)
+	os.Chdir(tmp)
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("Expected the default config to load, got %v", err)
+	}
+	return cfg
+}
+
+func TestHandlerDefaultGlobalLimitLeavesRoomForOtherClients(t *testing.T) {
+	cfg := loadDefaultConfig(t)
+	provider := &stubProvider{name: "Primary", data: &models.WeatherData{Temperature: 30}}
+	identifier, _ := ratelimit.NewClientIdentifier(cfg.RateLimitAPIKeyHeader, []string{"client-a", "client-b"}, nil)
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		newMapCache(),
+		ratelimit.NewTokenBucketLimiter(cfg.RateLimitGlobalRequests, cfg.RateLimitDuration),
+		stats.NewStatsTracker(),
+		handlers.WeatherHandlerOptions{
+			ClientLimiter:    ratelimit.NewKeyedLimiter(cfg.RateLimitRequests, cfg.RateLimitDuration, time.Minute, 10),
+			ClientIdentifier: identifier,
+		},
+	)
+
+	get := func(city, apiKey string) int {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/weather?city="+city, nil)
+		request.Header.Set(cfg.RateLimitAPIKeyHeader, apiKey)
+		handler.GetWeather(recorder, request)
+		return recorder.Code
+	}
+
+	for i := 0; i < 60; i++ {
+		get(fmt.Sprintf("City%d", i), "client-a")
+	}
+	if code := get("Jakarta", "client-b"); code != http.StatusOK {
+		t.Errorf("Expected another client to be served after one used up its own limit, got %d", code)
+	}
+}
+
+func TestCombinedLimiterReportsTightestLimit(t *testing.T) {
+	client := ratelimit.NewTokenBucketLimiter(1, time.Minute)
+	global := ratelimit.NewTokenBucketLimiter(10, time.Minute)
+	combined := ratelimit.Combined{client, global}
+
+	if status := combined.Take(); !status.Allowed || status.Limit != 1 || status.Remaining != 0 {
+		t.Errorf("Expected the client's limit to be reported, got %+v", status)
+	}
+	if status := combined.Take(); status.Allowed {
+		t.Error("Expected the client's limit to refuse the second request")
+	}
+	if remaining := global.Status().Remaining; remaining != 9 {
+		t.Errorf("Expected a refused request not to use up the global limit, got %d left", remaining)
+	}
+}
+
+func TestCombinedLimiterDoesNotChargeClientWhenGlobalRefuses(t *testing.T) {
+	client := ratelimit.NewTokenBucketLimiter(5, time.Minute)
+	global := ratelimit.NewTokenBucketLimiter(1, time.Minute)
+	combined := ratelimit.Combined{client, global}
+
+	combined.Take()
+	if status := combined.Take(); status.Allowed || status.RetryAfter <= 0 {
+		t.Errorf("Expected the global limit to refuse the second request, got %+v", status)
+	}
+	if remaining := client.Status().Remaining; remaining != 4 {
+		t.Errorf("Expected a request refused by the global limit not to use up the client's, got %d left", remaining)
+	}
+}
+
+func TestCombinedLimiterWaitDoesNotChargeClientWhenGlobalTimesOut(t *testing.T) {
+	client := ratelimit.NewTokenBucketLimiter(5, time.Minute)
+	global := ratelimit.NewTokenBucketLimiter(1, time.Minute)
+	combined := ratelimit.Combined{client, global}
+	combined.Take()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := combined.Wait(ctx); err == nil {
+		t.Fatal("Expected the wait for the global limit to fail")
+	}
+	if remaining := client.Status().Remaining; remaining != 4 {
+		t.Errorf("Expected a failed wait not to use up the client's limit, got %d left", remaining)
+	}
+}
+
+func TestHandlerQueuedRequestChargesClientOnce(t *testing.T) {
+	provider := &stubProvider{name: "Primary", data: &models.WeatherData{Temperature: 30}}
+	identifier, _ := ratelimit.NewClientIdentifier("X-API-Key", nil, nil)
+	clients := ratelimit.NewKeyedLimiter(5, time.Minute, time.Minute, 10)
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		newMapCache(),
+		ratelimit.NewTokenBucketLimiter(1, 100*time.Millisecond),
+		stats.NewStatsTracker(),
+		handlers.WeatherHandlerOptions{
+			ClientLimiter:      clients,
+			ClientIdentifier:   identifier,
+			RateLimitQueueWait: time.Second,
+		},
+	)
+
+	for _, city := range []string{"Jakarta", "Bandung"} {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/weather?city="+city, nil)
+		request.RemoteAddr = "203.0.113.1:5000"
+		handler.GetWeather(recorder, request)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected %s to be served, queueing if needed, got %d", city, recorder.Code)
+		}
+	}
+	if remaining := clients.Limiter("ip:203.0.113.1").Status().Remaining; remaining != 3 {
+		t.Errorf("Expected each request to take one token from the client's limit, got %d left", remaining)
+	}
+}
+
+// blackholeRedis accepts connections but never answers, like a Redis behind
+// a network that drops packets.
+func blackholeRedis(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestRedisLimiterSkipsUnresponsiveRedis(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: blackholeRedis(t), ReadTimeout: 100 * time.Millisecond})
+	defer client.Close()
+	limiter := ratelimit.NewRedisLimiter(client, "global", 1, time.Minute)
+
+	if !limiter.Take().Allowed {
+		t.Fatal("Expected the request to be allowed once Redis timed out")
+	}
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		limiter.Status()
+		if !limiter.Take().Allowed {
+			t.Fatal("Expected requests to be allowed while Redis is down")
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected Redis to be skipped after it timed out, took %v", elapsed)
+	}
+}
+
+// countingLimiter counts how often the limit is consulted.
+type countingLimiter struct {
+	ratelimit.RateLimiter
+	takes, statuses int
+}
+
+func (l *countingLimiter) Take() ratelimit.Status {
+	l.takes++
+	return l.RateLimiter.Take()
+}
+
+func (l *countingLimiter) Status() ratelimit.Status {
+	l.statuses++
+	return l.RateLimiter.Status()
+}
+
+func TestHandlerConsultsRateLimitOncePerRequest(t *testing.T) {
+	provider := &stubProvider{name: "Primary", data: &models.WeatherData{Temperature: 30}}
+	limiter := &countingLimiter{RateLimiter: ratelimit.NewTokenBucketLimiter(10, time.Minute)}
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		newMapCache(),
+		limiter,
+		stats.NewStatsTracker(),
+		handlers.WeatherHandlerOptions{},
+	)
+
+	getWeather(handler, "Jakarta")
+	if limiter.takes != 1 || limiter.statuses != 0 {
+		t.Errorf("Expected a miss to only take a request, got %d takes and %d lookups", limiter.takes, limiter.statuses)
+	}
+	recorder := getWeather(handler, "Jakarta")
+	if limiter.takes != 1 || limiter.statuses != 1 || recorder.Header().Get("RateLimit-Remaining") != "9" {
+		t.Errorf("Expected a hit to look the limit up once, got %d takes, %d lookups and headers %v", limiter.takes, limiter.statuses, recorder.Header())
+	}
+}
@@ -0,0 +1,235 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/api/handlers"
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"github.com/devonphone/weather-aggregator/internal/providers"
+	ratelimit "github.com/devonphone/weather-aggregator/internal/rate_limit"
+	"github.com/devonphone/weather-aggregator/internal/stats"
+)
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	provider := &stubProvider{name: "Flaky", err: errors.New("upstream down")}
+	var transitions []providers.BreakerState
+	breaker := providers.NewCircuitBreaker(provider, providers.CircuitBreakerConfig{
+		FailureThreshold: 2,
+		CoolOff:          20 * time.Millisecond,
+		OnStateChange: func(name string, from, to providers.BreakerState) {
+			transitions = append(transitions, to)
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		breaker.GetWeather(context.Background(), "Jakarta")
+	}
+	if state := breaker.Status().State; state != providers.BreakerOpen {
+		t.Fatalf("Expected breaker to open after 2 failures, got %s", state)
+	}
+
+	if _, err := breaker.GetWeather(context.Background(), "Jakarta"); !errors.Is(err, providers.ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen while open, got %v", err)
+	}
+	if provider.Calls() != 2 {
+		t.Errorf("Expected open breaker not to call the provider, got %d calls", provider.Calls())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	provider.err = nil
+	provider.data = &models.WeatherData{Temperature: 30}
+
+	if _, err := breaker.GetWeather(context.Background(), "Jakarta"); err != nil {
+		t.Fatalf("Expected half-open probe to succeed, got %v", err)
+	}
+	if state := breaker.Status().State; state != providers.BreakerClosed {
+		t.Errorf("Expected breaker to close after a successful probe, got %s", state)
+	}
+
+	expected := []providers.BreakerState{providers.BreakerOpen, providers.BreakerHalfOpen, providers.BreakerClosed}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected transitions %v, got %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("Expected transitions %v, got %v", expected, transitions)
+		}
+	}
+}
+
+func TestCircuitBreakerErrorRate(t *testing.T) {
+	provider := &stubProvider{name: "Flaky", data: &models.WeatherData{Temperature: 30}}
+	breaker := providers.NewCircuitBreaker(provider, providers.CircuitBreakerConfig{
+		ErrorRateThreshold: 0.5,
+		MinRequests:        4,
+		Window:             time.Minute,
+		CoolOff:            time.Minute,
+	})
+
+	// Alternate success and failure so consecutive failures never pile up
+	for i := 0; i < 4; i++ {
+		if i%2 == 0 {
+			provider.err = nil
+		} else {
+			provider.err = errors.New("upstream down")
+		}
+		breaker.GetWeather(context.Background(), "Jakarta")
+	}
+
+	if state := breaker.Status().State; state != providers.BreakerOpen {
+		t.Errorf("Expected breaker to open at 50%% error rate, got %s", state)
+	}
+}
+
+func TestCircuitBreakerIgnoresCanceledCalls(t *testing.T) {
+	provider := &stubProvider{name: "Slow", delay: time.Second, data: &models.WeatherData{}}
+	breaker := providers.NewCircuitBreaker(provider, providers.CircuitBreakerConfig{
+		FailureThreshold: 1,
+		CoolOff:          time.Minute,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	breaker.GetWeather(ctx, "Jakarta")
+
+	if state := breaker.Status().State; state != providers.BreakerClosed {
+		t.Errorf("Expected canceled calls not to trip the breaker, got %s", state)
+	}
+}
+
+// sequenceProvider returns the queued errors in order, then succeeds.
+type sequenceProvider struct {
+	errs  []error
+	calls int
+}
+
+func (p *sequenceProvider) GetWeather(ctx context.Context, city string) (*models.WeatherData, error) {
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return &models.WeatherData{City: city, Temperature: 30, Source: "Sequence"}, nil
+}
+
+func (p *sequenceProvider) GetProviderName() string {
+	return "Sequence"
+}
+
+func TestRetryingProviderRetriesTransientErrors(t *testing.T) {
+	provider := &sequenceProvider{errs: []error{
+		&providers.HTTPError{API: "Sequence", StatusCode: 503},
+		&providers.HTTPError{API: "Sequence", StatusCode: 429},
+	}}
+	retrying := providers.NewRetryingProvider(provider, providers.RetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    5 * time.Millisecond,
+	})
+
+	if _, err := retrying.GetWeather(context.Background(), "Jakarta"); err != nil {
+		t.Fatalf("Expected success on the third attempt, got %v", err)
+	}
+	if provider.calls != 3 {
+		t.Errorf("Expected 3 calls, got %d", provider.calls)
+	}
+}
+
+func TestRetryingProviderDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{401, 404} {
+		provider := &sequenceProvider{errs: []error{&providers.HTTPError{API: "Sequence", StatusCode: status}}}
+		retrying := providers.NewRetryingProvider(provider, providers.RetryConfig{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+		})
+
+		if _, err := retrying.GetWeather(context.Background(), "Jakarta"); err == nil {
+			t.Errorf("Expected %d to be returned, got nil", status)
+		}
+		if provider.calls != 1 {
+			t.Errorf("Expected %d not to be retried, got %d calls", status, provider.calls)
+		}
+	}
+}
+
+func TestRetryingProviderRespectsDeadline(t *testing.T) {
+	provider := &sequenceProvider{errs: []error{
+		&providers.HTTPError{API: "Sequence", StatusCode: 429, RetryAfter: time.Minute},
+	}}
+	retrying := providers.NewRetryingProvider(provider, providers.RetryConfig{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := retrying.GetWeather(ctx, "Jakarta"); err == nil {
+		t.Fatal("Expected the 429 to be returned when Retry-After exceeds the deadline")
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected to give up immediately, waited %s", elapsed)
+	}
+}
+
+func TestRetryingProviderRetriesHungAttempt(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// Hang until the client gives up on the attempt
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		w.Write([]byte(weatherAPICurrentJSON))
+	}))
+	defer server.Close()
+
+	retrying := providers.NewRetryingProvider(providers.NewWeatherAPIProviderWithURL("key", server.URL), providers.RetryConfig{
+		MaxAttempts: 2,
+		BaseDelay:   time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if _, err := retrying.GetWeather(ctx, "Boston"); err != nil {
+		t.Fatalf("Expected the second attempt to succeed after the first hung, got %v", err)
+	}
+	if calls := atomic.LoadInt32(&calls); calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
+
+func TestHandlerDoesNotCountCallsRejectedByOpenBreaker(t *testing.T) {
+	provider := &stubProvider{name: "Flaky", err: errors.New("upstream down")}
+	breaker := providers.NewCircuitBreaker(provider, providers.CircuitBreakerConfig{
+		FailureThreshold: 1,
+		CoolOff:          time.Minute,
+	})
+	statsTracker := stats.NewStatsTracker()
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{breaker},
+		newMapCache(),
+		ratelimit.NewTokenBucketLimiter(100, time.Minute),
+		statsTracker,
+		handlers.WeatherHandlerOptions{},
+	)
+
+	for _, city := range []string{"Jakarta", "Bandung", "Surabaya"} {
+		getWeather(handler, city)
+	}
+	if calls := statsTracker.GetStats().ApiCalls; calls != 1 || provider.Calls() != 1 {
+		t.Errorf("Expected only the call that reached the provider to count, got %d API calls and %d provider calls", calls, provider.Calls())
+	}
+}
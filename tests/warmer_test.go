@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devonphone/weather-aggregator/api/handlers"
+	"github.com/devonphone/weather-aggregator/internal/location"
+	"github.com/devonphone/weather-aggregator/internal/models"
+	"github.com/devonphone/weather-aggregator/internal/providers"
+	ratelimit "github.com/devonphone/weather-aggregator/internal/rate_limit"
+	"github.com/devonphone/weather-aggregator/internal/stats"
+	"github.com/devonphone/weather-aggregator/internal/warmer"
+)
+
+// recordingRefresher records the cities it is asked to warm.
+type recordingRefresher struct {
+	mu     sync.Mutex
+	cities []string
+}
+
+func (r *recordingRefresher) Warm(ctx context.Context, city string, refreshAhead time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cities = append(r.cities, city)
+	return true, nil
+}
+
+func (r *recordingRefresher) Cities() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.cities...)
+}
+
+func TestWarmerRanksPopularCities(t *testing.T) {
+	w := warmer.NewWarmer(warmer.Options{TopN: 2, MinRequests: 2})
+
+	for city, requests := range map[string]int{"Jakarta": 5, "Bandung": 3, "Surabaya": 4, "Medan": 1} {
+		for i := 0; i < requests; i++ {
+			w.Record(location.QueryKey(city), city)
+		}
+	}
+
+	if popular := w.Popular(); !reflect.DeepEqual(popular, []string{"Jakarta", "Surabaya"}) {
+		t.Errorf("Expected the two most requested cities, got %v", popular)
+	}
+}
+
+func TestWarmerWarmsPinnedCitiesOnStart(t *testing.T) {
+	w := warmer.NewWarmer(warmer.Options{Pinned: []string{"Jakarta"}, Interval: time.Hour})
+	refresher := &recordingRefresher{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx, refresher)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(refresher.Cities()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if cities := refresher.Cities(); !reflect.DeepEqual(cities, []string{"Jakarta"}) {
+		t.Errorf("Expected Jakarta to be warmed right away, got %v", cities)
+	}
+}
+
+func TestHandlerWarmRefreshesShortlyBeforeExpiry(t *testing.T) {
+	provider := &stubProvider{name: "Primary", data: &models.WeatherData{Temperature: 30}}
+	weatherCache := newMapCache()
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		weatherCache,
+		ratelimit.NewTokenBucketLimiter(100, time.Minute),
+		stats.NewStatsTracker(),
+		handlers.WeatherHandlerOptions{FreshFor: 10 * time.Minute},
+	)
+	ctx := context.Background()
+
+	weatherCache.Set(ctx, location.QueryKey("Jakarta"), cachedEntry(25, 5*time.Minute))
+	if refreshed, _ := handler.Warm(ctx, "Jakarta", 2*time.Minute); refreshed || provider.Calls() != 0 {
+		t.Fatal("Expected an entry far from expiry to be left alone")
+	}
+
+	weatherCache.Set(ctx, location.QueryKey("Jakarta"), cachedEntry(25, 9*time.Minute))
+	if refreshed, err := handler.Warm(ctx, "Jakarta", 2*time.Minute); !refreshed || err != nil {
+		t.Fatalf("Expected an entry about to expire to be refreshed, got %v", err)
+	}
+	if cached, _ := weatherCache.Get(ctx, location.QueryKey("Jakarta")); cached.Temperature != 30 || time.Since(*cached.CachedAt) > time.Second {
+		t.Errorf("Expected a freshly cached entry, got %+v", cached)
+	}
+}
+
+func TestWarmerMergesCountsOnceQueryResolves(t *testing.T) {
+	w := warmer.NewWarmer(warmer.Options{TopN: 1, MinRequests: 3})
+	resolved := "weather:" + location.KeyVersion + ":geo:-6.21,106.85"
+
+	w.Record(location.QueryKey("Jakarta"), "Jakarta")
+	w.Record(location.QueryKey("Jakarta"), "Jakarta")
+	w.Record(resolved, "Jakarta")
+
+	if popular := w.Popular(); !reflect.DeepEqual(popular, []string{"Jakarta"}) {
+		t.Errorf("Expected requests before and after resolving to add up, got %v", popular)
+	}
+}
+
+func TestHandlerWarmSkipsWhenRateLimited(t *testing.T) {
+	provider := &stubProvider{name: "Primary", data: &models.WeatherData{Temperature: 30}}
+	statsTracker := stats.NewStatsTracker()
+	limiter := ratelimit.NewTokenBucketLimiter(1, time.Minute)
+	limiter.Allow()
+	handler := handlers.NewWeatherHandler(
+		[]providers.WeatherProvider{provider},
+		newMapCache(),
+		limiter,
+		statsTracker,
+		handlers.WeatherHandlerOptions{},
+	)
+
+	refreshed, err := handler.Warm(context.Background(), "Jakarta", 2*time.Minute)
+	if refreshed || err != nil || provider.Calls() != 0 {
+		t.Errorf("Expected warming to be skipped quietly, got %v, %v", refreshed, err)
+	}
+	if hits := statsTracker.GetStats().RateLimitHits; hits != 0 {
+		t.Errorf("Expected throttled warming not to count as a rate limit hit, got %d", hits)
+	}
+}